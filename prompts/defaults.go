@@ -40,3 +40,14 @@ func Confirm(msg string) bool {
 		return Confirm(msg)
 	}
 }
+
+// ValidResponseWithDefaultPrompt prompts the user until the response is valid, and returns it without
+// surrounding whitespace. If the response is empty, the default is returned.
+func ValidResponseWithDefaultPrompt(msg, def string, valid func(string) bool) string {
+	res := strings.TrimSpace(RawResponseWithDefaultPrompt(msg, def))
+	if !valid(res) {
+		return ValidResponseWithDefaultPrompt(msg, def, valid)
+	}
+
+	return res
+}
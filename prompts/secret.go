@@ -0,0 +1,31 @@
+package prompts
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// SecretPrompt prompts the user for a response that is not shown on the screen while it is typed,
+// such as a password. If echo can not be turned off, the response is shown as it is typed.
+func SecretPrompt(msg string) string {
+	fmt.Print(msg + " >> ")
+
+	stty := func(arg string) error {
+		cmd := exec.Command("stty", arg)
+		cmd.Stdin = os.Stdin
+		return cmd.Run()
+	}
+
+	if err := stty("-echo"); err == nil {
+		defer func() {
+			stty("echo")
+			fmt.Println()
+		}()
+	}
+
+	res, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimRight(res, "\r\n")
+}
@@ -0,0 +1,257 @@
+package script
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/ethaniccc/simple-osharden/prompts"
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+const (
+	// auditRulesFile is the file in rules.d that AuditRules writes its rules to.
+	auditRulesFile = "/etc/audit/rules.d/50-osharden.rules"
+	// auditFinalizeFile makes the rules immutable. It has to be loaded after every other rule file.
+	auditFinalizeFile = "/etc/audit/rules.d/99-osharden-finalize.rules"
+)
+
+func init() {
+	RegisterScript(&AuditRules{})
+}
+
+// auditRuleGroup is a set of audit rules that can be installed by AuditRules. All the rules in a group
+// share the same key, so that their events can be searched with ausearch -k.
+type auditRuleGroup struct {
+	key         string
+	description string
+	// rules returns the rules of the group. It is only called if the group is selected, since some
+	// groups have to search the machine for the paths to audit.
+	rules func() []string
+}
+
+// auditRuleGroups are the rule groups that AuditRules can install.
+var auditRuleGroups = []auditRuleGroup{
+	{"identity", "changes to users, groups and passwords", func() []string {
+		return watchRules("wa", "identity", "/etc/passwd", "/etc/shadow", "/etc/group", "/etc/gshadow", "/etc/security/opasswd")
+	}},
+	{"scope", "changes to sudoers", func() []string {
+		return watchRules("wa", "scope", "/etc/sudoers", "/etc/sudoers.d")
+	}},
+	{"time-change", "changes to the system time", func() []string {
+		return append(
+			syscallRules("time-change", false, "adjtimex,settimeofday,clock_settime,stime"),
+			watchRules("wa", "time-change", "/etc/localtime")...,
+		)
+	}},
+	{"system-locale", "changes to the hostname and network config", func() []string {
+		return append(
+			syscallRules("system-locale", false, "sethostname,setdomainname"),
+			watchRules("wa", "system-locale", "/etc/issue", "/etc/issue.net", "/etc/hosts", "/etc/hostname",
+				"/etc/network", "/etc/netplan", "/etc/sysconfig/network", "/etc/NetworkManager")...,
+		)
+	}},
+	{"MAC-policy", "changes to AppArmor and SELinux policy", func() []string {
+		return watchRules("wa", "MAC-policy", "/etc/apparmor", "/etc/apparmor.d", "/etc/selinux", "/usr/share/selinux")
+	}},
+	{"logins", "logins and failed logins", func() []string {
+		return watchRules("wa", "logins", "/var/log/faillog", "/var/log/lastlog", "/var/log/tallylog", "/var/run/faillock")
+	}},
+	{"session", "session starts", func() []string {
+		return watchRules("wa", "session", "/var/run/utmp", "/var/log/wtmp", "/var/log/btmp")
+	}},
+	{"perm_mod", "changes to file permissions, owners and attributes by users", func() []string {
+		rules := syscallRules("perm_mod", true, "chmod,fchmod,fchmodat")
+		rules = append(rules, syscallRules("perm_mod", true, "chown,fchown,fchownat,lchown")...)
+		return append(rules, syscallRules("perm_mod", true, "setxattr,lsetxattr,fsetxattr,removexattr,lremovexattr,fremovexattr")...)
+	}},
+	{"privileged", "use of setuid and setgid programs by users", privilegedRules},
+	{"modules", "loading and unloading of kernel modules", func() []string {
+		return syscallRules("modules", false, "init_module,finit_module,delete_module")
+	}},
+	{"mounts", "filesystems mounted by users", func() []string {
+		return syscallRules("mounts", true, "mount,umount2")
+	}},
+}
+
+// AuditRules is a script that installs audit rules for the events that are most useful when looking
+// into what happened on a machine, and makes sure auditd keeps enough logs.
+type AuditRules struct {
+}
+
+func (s *AuditRules) Name() string {
+	return "auditrules"
+}
+
+func (s *AuditRules) Description() string {
+	return "Installs auditd rules and configures audit log retention."
+}
+
+func (s *AuditRules) RunOnLinux() error {
+	if _, err := exec.LookPath("augenrules"); err != nil {
+		return fmt.Errorf("auditd is not installed, install the auditd (or audit) package first")
+	}
+
+	lines := []string{"# Generated by Simple-OSHarden."}
+	keys := []string{}
+	for _, group := range auditRuleGroups {
+		if !prompts.Confirm(fmt.Sprintf("Should %s be audited?", group.description)) {
+			continue
+		}
+
+		rules := group.rules()
+		lines = append(lines, "", "# "+group.description)
+		lines = append(lines, rules...)
+		keys = append(keys, group.key)
+		logger.Infof("Added %d rule(s) for %s", len(rules), group.description)
+	}
+
+	if err := utils.WriteFile(auditRulesFile, []byte(strings.Join(lines, "\n")+"\n"), 0640); err != nil {
+		return fmt.Errorf("unable to write to %s: %s", auditRulesFile, err.Error())
+	}
+
+	if prompts.Confirm("Should the audit rules be made immutable (a reboot will be needed to change them)?") {
+		if err := utils.WriteFile(auditFinalizeFile, []byte("# Generated by Simple-OSHarden.\n-e 2\n"), 0640); err != nil {
+			return fmt.Errorf("unable to write to %s: %s", auditFinalizeFile, err.Error())
+		}
+	} else if err := os.Remove(auditFinalizeFile); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unable to remove %s: %s", auditFinalizeFile, err.Error())
+	}
+
+	maxLogFile := prompts.RawResponseWithDefaultPrompt("How large can each audit log be in MB? (recommended is 32)", "32")
+	numLogs := prompts.RawResponseWithDefaultPrompt("How many audit logs should be kept? (recommended is 10)", "10")
+	if err := utils.WriteOptsToFile(map[string]string{
+		"local_events":        "yes",
+		"max_log_file":        maxLogFile,
+		"num_logs":            numLogs,
+		"max_log_file_action": "rotate",
+		// Warn when the disk is getting full, and stop writing logs instead of losing the oldest ones
+		// when it is almost full.
+		"space_left_action":       "syslog",
+		"admin_space_left_action": "suspend",
+		"disk_full_action":        "suspend",
+	}, " = ", "/etc/audit/auditd.conf"); err != nil {
+		return fmt.Errorf("unable to write to /etc/audit/auditd.conf: %s", err.Error())
+	}
+
+	if err := ExecuteLoggedCommands([]LoggedCommand{
+		{"Loading the audit rules", "augenrules --load", false},
+		// auditd refuses to be restarted by systemctl on some distros, but not by the service command.
+		{"Restarting auditd", "service auditd restart", true},
+	}); err != nil {
+		return err
+	}
+
+	return s.verify(keys)
+}
+
+// verify checks that the kernel has loaded rules for each of the given keys.
+func (s *AuditRules) verify(keys []string) error {
+	out, err := GetCommandOutput("auditctl -l")
+	if err != nil {
+		return fmt.Errorf("unable to list the loaded audit rules: %s", err.Error())
+	}
+
+	report := &Report{}
+	for _, key := range keys {
+		if !strings.Contains(out, "-k "+key) && !strings.Contains(out, "key="+key) {
+			report.Flag(key, "no rules with this key are loaded")
+		}
+	}
+
+	if status, err := GetCommandOutput("auditctl -s"); err == nil && strings.Contains(status, "enabled 2") {
+		logger.Warn("The audit rules were already immutable, the new rules will be loaded after a reboot")
+	}
+
+	report.Summarize()
+	return nil
+}
+
+// watchRules returns rules that watch the given paths for the given kinds of access. Paths that do
+// not exist are left out, since auditctl fails to load watches for them.
+func watchRules(perms, key string, paths ...string) []string {
+	rules := []string{}
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+
+		rules = append(rules, fmt.Sprintf("-w %s -p %s -k %s", path, perms, key))
+	}
+
+	return rules
+}
+
+// b32OnlySyscalls are syscalls that only exist in the 32-bit syscall table, which is still used by
+// 32-bit programs on 64-bit machines.
+var b32OnlySyscalls = map[string]bool{"stime": true}
+
+// syscallRules returns rules that audit the given syscalls for every architecture the machine can
+// run. If byUsers is true, only syscalls made by users who logged in are audited.
+func syscallRules(key string, byUsers bool, syscalls string) []string {
+	arches := []string{"b32"}
+	if strings.HasSuffix(runtime.GOARCH, "64") {
+		arches = []string{"b64", "b32"}
+	}
+
+	filter := ""
+	if byUsers {
+		filter = fmt.Sprintf(" -F auid>=%d -F auid!=unset", uidMin())
+	}
+
+	rules := []string{}
+	for _, arch := range arches {
+		// Some syscalls do not exist on every architecture (arm64 has no chmod for example), and
+		// auditctl refuses to load rules with unknown syscalls.
+		supported := []string{}
+		for _, syscall := range strings.Split(syscalls, ",") {
+			if arch != "b32" && b32OnlySyscalls[syscall] {
+				continue
+			}
+
+			if syscallExists(arch, syscall) {
+				supported = append(supported, syscall)
+			}
+		}
+
+		if len(supported) == 0 {
+			continue
+		}
+
+		rules = append(rules, fmt.Sprintf("-a always,exit -F arch=%s -S %s%s -k %s", arch, strings.Join(supported, ","), filter, key))
+	}
+
+	return rules
+}
+
+// syscallExists returns true if the syscall exists on the given audit architecture. If ausyscall is
+// not installed, every syscall is assumed to exist.
+func syscallExists(arch, syscall string) bool {
+	if _, err := exec.LookPath("ausyscall"); err != nil {
+		return true
+	}
+
+	_, err := GetCommandOutputWithArgs("ausyscall", arch, syscall, "--exact")
+	return err == nil
+}
+
+// privilegedRules returns a rule for every setuid and setgid program on the machine, which audits
+// every time a user runs it.
+func privilegedRules() []string {
+	logger.Info("Looking for setuid and setgid programs, this may take a while")
+	paths := []string{}
+	for _, f := range findSUIDFiles() {
+		paths = append(paths, f.Path)
+	}
+	sort.Strings(paths)
+
+	rules := []string{}
+	for _, path := range paths {
+		rules = append(rules, fmt.Sprintf("-a always,exit -F path=%s -F perm=x -F auid>=%d -F auid!=unset -k privileged", path, uidMin()))
+	}
+
+	return rules
+}
@@ -0,0 +1,280 @@
+package script
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/ethaniccc/simple-osharden/prompts"
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+const (
+	// aptPeriodicFile enables the daily apt jobs that run unattended-upgrades.
+	aptPeriodicFile = "/etc/apt/apt.conf.d/20auto-upgrades"
+	// aptUnattendedFile overrides the options in 50unattended-upgrades, since it is read after it.
+	aptUnattendedFile = "/etc/apt/apt.conf.d/52osharden-unattended-upgrades"
+
+	// zypperPatchUnit is the name of the systemd service and timer that install security patches on SUSE.
+	zypperPatchUnit = "osharden-zypper-patch"
+)
+
+func init() {
+	RegisterScript(&AutoUpdates{})
+}
+
+// updatePolicy is how automatic updates should be installed, as chosen by the user.
+type updatePolicy struct {
+	// Reboot is true if the machine should reboot by itself when an update needs it.
+	Reboot bool
+	// RebootTime is the time of day that the machine reboots at, such as "02:00".
+	RebootTime string
+	// Email is the address that update reports are sent to. They are only logged if it is empty.
+	Email string
+	// Blacklist are the packages that are never updated automatically.
+	Blacklist []string
+}
+
+// AutoUpdates is a script that makes the package manager install security updates by itself every
+// day, so that the machine stays patched after it has been hardened.
+type AutoUpdates struct {
+}
+
+func (s *AutoUpdates) Name() string {
+	return "autoupdates"
+}
+
+func (s *AutoUpdates) Description() string {
+	return "Sets up automatic security updates."
+}
+
+func (s *AutoUpdates) RunOnLinux() error {
+	policy := updatePolicy{}
+	if policy.Reboot = prompts.Confirm("Should the machine reboot by itself when an update needs it?"); policy.Reboot {
+		policy.RebootTime = prompts.RawResponseWithDefaultPrompt("What time should the machine reboot at? (default is 02:00)", "02:00")
+	}
+
+	policy.Email = prompts.RawResponsePrompt("Which email address should update reports be sent to? (leave empty to only log them)")
+	policy.Blacklist = strings.Fields(prompts.RawResponsePrompt("Which packages should never be updated automatically, separated by spaces? (leave empty for none)"))
+
+	var timers []string
+	var err error
+	switch distroFamily() {
+	case "debian":
+		timers, err = s.configureApt(policy)
+	case "rhel":
+		timers, err = s.configureDnf(policy)
+	case "suse":
+		timers, err = s.configureZypper(policy)
+	default:
+		return fmt.Errorf("automatic updates are not supported on this distro")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return s.verifyTimers(timers)
+}
+
+// configureApt sets up unattended-upgrades, and returns the timers that run it.
+func (s *AutoUpdates) configureApt(policy updatePolicy) ([]string, error) {
+	if _, err := exec.LookPath("unattended-upgrade"); err != nil {
+		if err := ExecuteLoggedCommands([]LoggedCommand{
+			{"Installing unattended-upgrades", "apt-get install -y unattended-upgrades", false},
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	periodic := []string{
+		`APT::Periodic::Update-Package-Lists "1";`,
+		`APT::Periodic::Unattended-Upgrade "1";`,
+		`APT::Periodic::AutocleanInterval "7";`,
+	}
+	if err := utils.WriteFile(aptPeriodicFile, []byte(strings.Join(periodic, "\n")+"\n"), 0644); err != nil {
+		return nil, fmt.Errorf("unable to write to %s: %s", aptPeriodicFile, err.Error())
+	}
+
+	// Only the security origins are enabled in 50unattended-upgrades by default, so they are left alone.
+	opts := []string{
+		"// Generated by Simple-OSHarden.",
+		`Unattended-Upgrade::SyslogEnable "true";`,
+		fmt.Sprintf(`Unattended-Upgrade::Automatic-Reboot "%t";`, policy.Reboot),
+	}
+
+	if policy.Reboot {
+		opts = append(opts, fmt.Sprintf(`Unattended-Upgrade::Automatic-Reboot-Time "%s";`, policy.RebootTime))
+	}
+
+	if policy.Email != "" {
+		opts = append(opts, fmt.Sprintf(`Unattended-Upgrade::Mail "%s";`, policy.Email), `Unattended-Upgrade::MailReport "on-change";`)
+	}
+
+	if len(policy.Blacklist) != 0 {
+		opts = append(opts, "Unattended-Upgrade::Package-Blacklist {")
+		for _, pkg := range policy.Blacklist {
+			opts = append(opts, fmt.Sprintf(`	"%s";`, pkg))
+		}
+		opts = append(opts, "};")
+	}
+
+	if err := utils.WriteFile(aptUnattendedFile, []byte(strings.Join(opts, "\n")+"\n"), 0644); err != nil {
+		return nil, fmt.Errorf("unable to write to %s: %s", aptUnattendedFile, err.Error())
+	}
+
+	out, err := GetCommandOutput("apt-config dump APT::Periodic::Unattended-Upgrade")
+	if err != nil || !strings.Contains(out, `"1"`) {
+		logger.Warnf("APT::Periodic::Unattended-Upgrade is overridden by another file in /etc/apt/apt.conf.d")
+	}
+
+	return []string{"apt-daily.timer", "apt-daily-upgrade.timer"}, nil
+}
+
+// configureDnf sets up dnf-automatic to install security updates, and returns the timer that runs it.
+func (s *AutoUpdates) configureDnf(policy updatePolicy) ([]string, error) {
+	// Fedora 41 and newer ship dnf5, which has its own version of dnf-automatic.
+	timer := "dnf-automatic.timer"
+	if _, err := exec.LookPath("dnf5"); err == nil {
+		timer = "dnf5-automatic.timer"
+	}
+
+	if _, err := os.Stat("/etc/dnf/automatic.conf"); os.IsNotExist(err) {
+		pkg := "dnf-automatic"
+		if timer == "dnf5-automatic.timer" {
+			pkg = "dnf5-plugin-automatic"
+		}
+
+		if err := RunCommand("dnf install -y " + pkg); err != nil {
+			return nil, fmt.Errorf("unable to install %s: %s", pkg, err.Error())
+		}
+	}
+
+	f, err := utils.LoadINIFile("/etc/dnf/automatic.conf")
+	if err != nil {
+		return nil, err
+	}
+
+	reboot := "never"
+	if policy.Reboot {
+		reboot = "when-needed"
+		setINIOption(f, "commands", "reboot_command", fmt.Sprintf(`"shutdown -r %s 'Rebooting after applying package updates'"`, policy.RebootTime))
+	}
+
+	setINIOption(f, "commands", "upgrade_type", "security")
+	setINIOption(f, "commands", "download_updates", "yes")
+	setINIOption(f, "commands", "apply_updates", "yes")
+	setINIOption(f, "commands", "reboot", reboot)
+
+	if policy.Email != "" {
+		setINIOption(f, "emitters", "emit_via", "email")
+		setINIOption(f, "email", "email_to", policy.Email)
+	} else {
+		// stdio is written to the journal of the dnf-automatic service.
+		setINIOption(f, "emitters", "emit_via", "stdio")
+	}
+
+	// The base section overrides the options in dnf.conf when dnf-automatic runs.
+	if len(policy.Blacklist) != 0 {
+		setINIOption(f, "base", "exclude", strings.Join(policy.Blacklist, " "))
+	} else {
+		f.Delete("base", "exclude")
+	}
+
+	if err := f.Save(); err != nil {
+		return nil, err
+	}
+
+	return []string{timer}, nil
+}
+
+// configureZypper installs a timer that applies security patches with zypper, since zypper has no
+// built in way to update automatically. It returns the timer.
+func (s *AutoUpdates) configureZypper(policy updatePolicy) ([]string, error) {
+	if policy.Email != "" {
+		logger.Warn("zypper can not send update reports by email, they will be written to the journal instead")
+	}
+
+	// Locked packages are never updated or patched by zypper, even by hand, until they are unlocked
+	// with zypper removelock.
+	for _, pkg := range policy.Blacklist {
+		if err := RunCommandWithArgs("zypper", "--non-interactive", "addlock", pkg); err != nil {
+			logger.Errorf("unable to lock %s: %s", pkg, err.Error())
+		}
+	}
+
+	service := []string{
+		"# Generated by Simple-OSHarden.",
+		"[Unit]",
+		"Description=Install security patches with zypper",
+		"Wants=network-online.target",
+		"After=network-online.target",
+		"",
+		"[Service]",
+		"Type=oneshot",
+		"ExecStart=/usr/bin/zypper --non-interactive patch --category security --auto-agree-with-licenses",
+		// zypper exits with 102 or 103 when the installed patches need a reboot or a restart of zypper.
+		"SuccessExitStatus=102 103",
+	}
+
+	// zypper needs-rebooting also exits with 102 when an installed patch needs a reboot.
+	if policy.Reboot {
+		service = append(service, fmt.Sprintf(`ExecStartPost=/bin/sh -c '/usr/bin/zypper needs-rebooting; [ $? -ne 102 ] || /sbin/shutdown -r %s'`, policy.RebootTime))
+	}
+
+	timer := []string{
+		"# Generated by Simple-OSHarden.",
+		"[Unit]",
+		"Description=Install security patches with zypper every day",
+		"",
+		"[Timer]",
+		"OnCalendar=daily",
+		"RandomizedDelaySec=1h",
+		"Persistent=true",
+		"",
+		"[Install]",
+		"WantedBy=timers.target",
+	}
+
+	files := map[string][]string{
+		"/etc/systemd/system/" + zypperPatchUnit + ".service": service,
+		"/etc/systemd/system/" + zypperPatchUnit + ".timer":   timer,
+	}
+	for file, lines := range files {
+		if err := utils.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+			return nil, fmt.Errorf("unable to write to %s: %s", file, err.Error())
+		}
+	}
+
+	if err := RunCommand("systemctl daemon-reload"); err != nil {
+		return nil, fmt.Errorf("unable to reload systemd: %s", err.Error())
+	}
+
+	return []string{zypperPatchUnit + ".timer"}, nil
+}
+
+// verifyTimers makes sure each of the timers is enabled and running, so that updates are actually
+// installed.
+func (s *AutoUpdates) verifyTimers(timers []string) error {
+	report := &Report{}
+	for _, timer := range timers {
+		if _, err := GetCommandOutput("systemctl is-enabled " + timer); err == nil {
+			if _, err := GetCommandOutput("systemctl is-active " + timer); err == nil {
+				logger.Infof("%s is enabled", timer)
+				continue
+			}
+		}
+
+		f := report.Flag(timer, "timer is not enabled")
+		if err := RunCommand("systemctl enable --now " + timer); err != nil {
+			logger.Errorf("unable to enable %s: %s", timer, err.Error())
+			continue
+		}
+
+		f.Fixed = true
+	}
+
+	report.Summarize()
+	return nil
+}
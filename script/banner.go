@@ -0,0 +1,100 @@
+package script
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+// bannerTemplate is the file that the text of the login banner is read from, if it exists.
+const bannerTemplate = "/etc/osharden/banner.txt"
+
+// defaultBanner is the login banner used when there is no banner template.
+const defaultBanner = `Authorized uses only. All activity may be monitored and reported.
+This system is for the use of authorized users only. Individuals using this
+computer system without authority, or in excess of their authority, are subject
+to having all of their activities on this system monitored and recorded.
+Anyone using this system expressly consents to such monitoring.`
+
+// issueEscape matches the escapes that getty replaces with details about the machine, such as the
+// OS release (\r), version (\v) and architecture (\m).
+var issueEscape = regexp.MustCompile(`\\[a-zA-Z0-9](\{[^}]*\})?`)
+
+func init() {
+	RegisterScript(&LoginBanner{})
+}
+
+// LoginBanner is a script that shows a legal warning before users log in on the console, over SSH
+// and on the graphical login screen, without giving away details about the OS.
+type LoginBanner struct {
+}
+
+func (s *LoginBanner) Name() string {
+	return "banner"
+}
+
+func (s *LoginBanner) Description() string {
+	return "Sets a legal warning banner for console, SSH and graphical logins."
+}
+
+func (s *LoginBanner) RunOnLinux() error {
+	text := defaultBanner
+	if buffer, err := os.ReadFile(bannerTemplate); err == nil {
+		logger.Infof("Using the banner from %s", bannerTemplate)
+		text = string(buffer)
+	} else {
+		logger.Infof("%s does not exist, using the default banner", bannerTemplate)
+	}
+
+	text = strings.TrimSpace(issueEscape.ReplaceAllString(text, "")) + "\n"
+	for _, file := range []string{"/etc/issue", "/etc/issue.net", "/etc/motd"} {
+		if err := utils.WriteFile(file, []byte(text), 0644); err != nil {
+			return fmt.Errorf("unable to write to %s: %s", file, err.Error())
+		}
+		logger.Infof("Wrote the banner to %s", file)
+	}
+
+	// Ubuntu shows OS details in the message of the day through these scripts, after /etc/motd.
+	if _, err := os.Stat("/etc/update-motd.d"); err == nil {
+		logger.Warn("/etc/update-motd.d exists, its scripts may still show OS details after login")
+	}
+
+	if err := s.setSSHBanner(); err != nil {
+		logger.Errorf("unable to set the SSH banner: %s", err.Error())
+	}
+
+	for _, dm := range installedDisplayManagers() {
+		switch dm.Name {
+		case gdm.Name:
+			if err := setGDMLoginScreenOptions("01-banner-message", map[string]string{
+				"banner-message-enable": "true",
+				"banner-message-text":   gvariantString(strings.TrimSpace(text)),
+			}); err != nil {
+				logger.Errorf("unable to set the GDM banner: %s", err.Error())
+			}
+		default:
+			logger.Warnf("%s does not support a login banner, it has to be set in the greeter theme", dm.Name)
+		}
+	}
+
+	return nil
+}
+
+// setSSHBanner makes the SSH server show /etc/issue.net before authentication.
+func (s *LoginBanner) setSSHBanner() error {
+	if !sshdInstalled() {
+		logger.Info("The SSH server is not installed, skipping")
+		return nil
+	}
+
+	return setSSHDOption("Banner", "/etc/issue.net")
+}
+
+// gvariantString quotes the string so that it can be used as a string value in a dconf keyfile.
+func gvariantString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`, "\n", `\n`)
+	return "'" + r.Replace(s) + "'"
+}
@@ -66,6 +66,12 @@ func GetCommandOutput(c string) (string, error) {
 	return string(out), err
 }
 
+// GetCommandOutputWithArgs runs a command with specific arguments and returns the output.
+func GetCommandOutputWithArgs(c string, args ...string) (string, error) {
+	out, err := exec.Command(c, args...).Output()
+	return string(out), err
+}
+
 // ConfirmCommand runs a command if the user confirms it should be run.
 func ConfirmCommand(msg, c string) error {
 	if !prompts.Confirm(msg) {
@@ -0,0 +1,421 @@
+package script
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ethaniccc/simple-osharden/prompts"
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+func init() {
+	RegisterScript(&CronAudit{})
+}
+
+// persistencePatterns match commands that are commonly used by attackers to download and run code,
+// stage payloads or open reverse shells.
+var persistencePatterns = []struct {
+	re     *regexp.Regexp
+	reason string
+}{
+	{regexp.MustCompile(`(curl|wget|fetch)\s.*\|\s*(sudo\s+)?(ba|da|z)?sh\b`), "downloads and runs code"},
+	{regexp.MustCompile(`(curl|wget)\s.*(&&|;)\s*(chmod\s+\+x|(ba|da|z)?sh\s|\./)`), "downloads and runs code"},
+	{regexp.MustCompile(`base64\s+(-d|--decode).*\|\s*(ba|da|z)?sh\b`), "runs encoded code"},
+	{regexp.MustCompile(`(^|[\s'"=:;])/(tmp|var/tmp|dev/shm)/`), "references a world-writable directory"},
+	{regexp.MustCompile(`/dev/(tcp|udp)/`), "reverse shell"},
+	{regexp.MustCompile(`\b(nc|ncat|netcat)\b.*\s-[a-z]*[ec]\s`), "reverse shell"},
+	{regexp.MustCompile(`\b(ba)?sh\s+-i\b`), "interactive shell"},
+	{regexp.MustCompile(`\bsocat\b.*exec:`), "reverse shell"},
+	{regexp.MustCompile(`\bmkfifo\b`), "named pipe, often used for reverse shells"},
+	{regexp.MustCompile(`(python[0-9.]*|perl|ruby|php)\s+-[a-z]*[ecr]\s.*socket`), "reverse shell"},
+}
+
+// persistenceReasons returns why the given content looks malicious, or nothing if it looks safe.
+func persistenceReasons(content string) []string {
+	reasons := []string{}
+	seen := map[string]bool{}
+	for _, p := range persistencePatterns {
+		if p.re.MatchString(content) && !seen[p.reason] {
+			reasons = append(reasons, p.reason)
+			seen[p.reason] = true
+		}
+	}
+
+	return reasons
+}
+
+// cronJobKind is the kind of scheduled task a cronJob is.
+type cronJobKind int
+
+const (
+	// cronJobLine is a line in a crontab file.
+	cronJobLine cronJobKind = iota
+	// cronJobScript is a script in one of the /etc/cron.{hourly,daily,weekly,monthly} directories.
+	cronJobScript
+	// cronJobAt is a job queued with at.
+	cronJobAt
+	// cronJobTimer is a systemd timer.
+	cronJobTimer
+)
+
+// cronJob is a single scheduled task found by CronAudit.
+type cronJob struct {
+	kind     cronJobKind
+	source   string
+	raw      string
+	schedule string
+	user     string
+	command  string
+	reasons  []string
+	finding  *Finding
+}
+
+// CronAudit is a script that lists every scheduled task on the machine, from cron, at and systemd
+// timers. Tasks that look malicious are flagged, and the user is able to disable or remove them.
+type CronAudit struct {
+}
+
+func (s *CronAudit) Name() string {
+	return "cronaudit"
+}
+
+func (s *CronAudit) Description() string {
+	return "Audits cron jobs, at jobs and systemd timers for persistence."
+}
+
+func (s *CronAudit) RunOnLinux() error {
+	jobs := []*cronJob{}
+
+	system, _ := filepath.Glob("/etc/cron.d/*")
+	for _, file := range append([]string{"/etc/crontab"}, system...) {
+		jobs = append(jobs, s.parseCrontab(file, "")...)
+	}
+
+	for _, dir := range []string{"/var/spool/cron/crontabs", "/var/spool/cron"} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				jobs = append(jobs, s.parseCrontab(filepath.Join(dir, entry.Name()), entry.Name())...)
+			}
+		}
+	}
+
+	for _, period := range []string{"hourly", "daily", "weekly", "monthly"} {
+		scripts, _ := filepath.Glob(fmt.Sprintf("/etc/cron.%s/*", period))
+		for _, script := range scripts {
+			buffer, err := os.ReadFile(script)
+			if err != nil {
+				continue
+			}
+
+			jobs = append(jobs, &cronJob{
+				kind:     cronJobScript,
+				source:   script,
+				schedule: "@" + period,
+				user:     "root",
+				command:  script,
+				reasons:  persistenceReasons(string(buffer)),
+			})
+		}
+	}
+
+	jobs = append(jobs, s.atJobs()...)
+	jobs = append(jobs, s.timers()...)
+
+	report := &Report{}
+	flagged, other := []*cronJob{}, []*cronJob{}
+	for _, job := range jobs {
+		logger.Infof("[%s] %s (user=%s): %s", job.source, job.schedule, job.user, job.command)
+		if len(job.reasons) == 0 {
+			other = append(other, job)
+			continue
+		}
+
+		job.finding = report.Flag(job.source, fmt.Sprintf("%q %s", job.command, strings.Join(job.reasons, ", ")))
+		flagged = append(flagged, job)
+	}
+
+	for _, job := range flagged {
+		s.review(job)
+	}
+
+	if len(other) != 0 && prompts.Confirm(fmt.Sprintf("Would you like to review the other %d scheduled task(s)?", len(other))) {
+		for _, job := range other {
+			s.review(job)
+		}
+	}
+
+	report.Summarize()
+	return nil
+}
+
+// parseCrontab returns the jobs in a crontab file. If user is empty, the file is in the system format
+// where each line includes the user the command runs as.
+func (s *CronAudit) parseCrontab(file, user string) []*cronJob {
+	buffer, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+
+	jobs := []*cronJob{}
+	for _, line := range strings.Split(string(buffer), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		fields := strings.Fields(trimmed)
+		// Lines such as SHELL=/bin/sh set environment variables.
+		if strings.Contains(fields[0], "=") {
+			continue
+		}
+
+		scheduleFields := 5
+		if strings.HasPrefix(fields[0], "@") {
+			scheduleFields = 1
+		}
+
+		commandStart := scheduleFields
+		if user == "" {
+			commandStart++
+		}
+
+		if len(fields) <= commandStart {
+			continue
+		}
+
+		job := &cronJob{
+			kind:     cronJobLine,
+			source:   file,
+			raw:      line,
+			schedule: strings.Join(fields[:scheduleFields], " "),
+			user:     user,
+			command:  strings.Join(fields[commandStart:], " "),
+		}
+
+		if user == "" {
+			job.user = fields[scheduleFields]
+		}
+
+		job.reasons = persistenceReasons(job.command)
+		jobs = append(jobs, job)
+	}
+
+	return jobs
+}
+
+// atJobs returns the jobs that are queued with at.
+func (s *CronAudit) atJobs() []*cronJob {
+	out, err := GetCommandOutput("atq")
+	if err != nil {
+		return nil
+	}
+
+	jobs := []*cronJob{}
+	for _, line := range strings.Split(out, "\n") {
+		// Each line is the job id, the time it runs, the queue and the user.
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
+			continue
+		}
+
+		id := fields[0]
+		content, err := GetCommandOutput("at -c " + id)
+		if err != nil {
+			continue
+		}
+
+		command := s.atJobCommand(content)
+		jobs = append(jobs, &cronJob{
+			kind:     cronJobAt,
+			source:   "at job " + id,
+			raw:      id,
+			schedule: strings.Join(fields[1:len(fields)-2], " "),
+			user:     fields[len(fields)-1],
+			command:  strings.Join(strings.Split(command, "\n"), "; "),
+			reasons:  persistenceReasons(command),
+		})
+	}
+
+	return jobs
+}
+
+// atJobCommand returns the commands of an at job, as shown by at -c. The job first exports the
+// environment it was queued from, which is left out, and then passes the commands to the shell in
+// a heredoc such as "${SHELL:-/bin/sh} << 'marcinDELIMITER3b2c1a4e'".
+func (s *CronAudit) atJobCommand(content string) string {
+	lines := strings.Split(strings.TrimSpace(content), "\n")
+	for i, line := range lines {
+		index := strings.Index(line, "<< 'marcinDELIMITER")
+		if index == -1 {
+			continue
+		}
+
+		delimiter := strings.Trim(line[index+3:], "'")
+		commands := []string{}
+		for _, l := range lines[i+1:] {
+			if l == delimiter {
+				break
+			}
+			commands = append(commands, l)
+		}
+
+		return strings.TrimSpace(strings.Join(commands, "\n"))
+	}
+
+	// Older versions of at write the commands directly after the block that changes to the
+	// directory the job was queued from.
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.TrimSpace(lines[i]) == "}" {
+			return strings.TrimSpace(strings.Join(lines[i+1:], "\n"))
+		}
+	}
+
+	return lines[len(lines)-1]
+}
+
+// timers returns the systemd timers on the machine, along with the services they trigger.
+func (s *CronAudit) timers() []*cronJob {
+	seen := map[string]bool{}
+	jobs := []*cronJob{}
+	for _, dir := range []string{"/etc/systemd/system", "/lib/systemd/system", "/usr/lib/systemd/system"} {
+		files, _ := filepath.Glob(filepath.Join(dir, "*.timer"))
+		sort.Strings(files)
+		for _, file := range files {
+			name := filepath.Base(file)
+			// Units in /etc override units with the same name in /lib.
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			timer, err := utils.LoadINIFile(file)
+			if err != nil {
+				continue
+			}
+
+			schedule := []string{}
+			for _, key := range []string{"OnCalendar", "OnBootSec", "OnStartupSec", "OnActiveSec", "OnUnitActiveSec", "OnUnitInactiveSec"} {
+				if v, ok := timer.Get("Timer", key); ok {
+					schedule = append(schedule, key+"="+v)
+				}
+			}
+
+			unit, ok := timer.Get("Timer", "Unit")
+			if !ok {
+				unit = strings.TrimSuffix(name, ".timer") + ".service"
+			}
+
+			job := &cronJob{
+				kind:     cronJobTimer,
+				source:   file,
+				raw:      name,
+				schedule: strings.Join(schedule, " "),
+				user:     "root",
+				command:  unit,
+			}
+
+			for _, d := range []string{"/etc/systemd/system", "/lib/systemd/system", "/usr/lib/systemd/system"} {
+				service, err := utils.LoadINIFile(filepath.Join(d, unit))
+				if err != nil {
+					continue
+				}
+
+				exec, ok := service.Get("Service", "ExecStart")
+				if !ok {
+					continue
+				}
+
+				job.command = fmt.Sprintf("%s (%s)", unit, exec)
+				if user, ok := service.Get("Service", "User"); ok {
+					job.user = user
+				}
+				break
+			}
+
+			job.reasons = persistenceReasons(job.command)
+			jobs = append(jobs, job)
+		}
+	}
+
+	return jobs
+}
+
+// review asks the user what should be done with the job, and then disables or removes it.
+func (s *CronAudit) review(job *cronJob) {
+	action := strings.ToLower(prompts.RawResponseWithDefaultPrompt(fmt.Sprintf("What should be done with [%s] %s? (disable/remove/keep)", job.source, job.command), "keep"))
+	if action != "disable" && action != "remove" {
+		return
+	}
+
+	var err error
+	switch job.kind {
+	case cronJobLine:
+		err = s.editCrontab(job, action == "remove")
+	case cronJobScript:
+		if action == "remove" {
+			err = os.Remove(job.source)
+		} else {
+			// run-parts skips scripts that are not executable.
+			var info os.FileInfo
+			if info, err = os.Stat(job.source); err == nil {
+				err = os.Chmod(job.source, info.Mode().Perm()&^0111)
+			}
+		}
+	case cronJobAt:
+		err = RunCommand("atrm " + job.raw)
+	case cronJobTimer:
+		err = RunCommand("systemctl disable --now " + job.raw)
+		if err == nil && action == "remove" && strings.HasPrefix(job.source, "/etc/") {
+			if err = os.Remove(job.source); err == nil {
+				err = RunCommand("systemctl daemon-reload")
+			}
+		} else if err == nil && action == "remove" {
+			// Timers shipped by packages are masked instead of deleted.
+			err = RunCommand("systemctl mask " + job.raw)
+		}
+	}
+
+	if err != nil {
+		logger.Errorf("unable to %s %s: %s", action, job.source, err.Error())
+		return
+	}
+
+	logger.Infof("%s: %sd %s", job.source, action, job.command)
+	if job.finding != nil {
+		job.finding.Fixed = true
+	}
+}
+
+// editCrontab comments out or removes the job's line from its crontab file.
+func (s *CronAudit) editCrontab(job *cronJob, remove bool) error {
+	buffer, err := os.ReadFile(job.source)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(buffer), "\n")
+	for i, line := range lines {
+		if line != job.raw {
+			continue
+		}
+
+		if remove {
+			lines = append(lines[:i], lines[i+1:]...)
+		} else {
+			lines[i] = "# Disabled by Simple-OSHarden: " + line
+		}
+
+		return utils.WriteFile(job.source, []byte(strings.Join(lines, "\n")), 0600)
+	}
+
+	return fmt.Errorf("the job is no longer in %s", job.source)
+}
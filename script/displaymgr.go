@@ -0,0 +1,248 @@
+package script
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+func init() {
+	RegisterScript(&DisplayManagerLogin{})
+}
+
+// displayManager is a display manager that can be installed on the machine.
+type displayManager struct {
+	// Name is the name of the display manager.
+	Name string
+	// Paths are files or directories that indicate the display manager is installed.
+	Paths []string
+}
+
+var (
+	lightDM = displayManager{Name: "lightdm", Paths: []string{"/etc/lightdm", "/usr/sbin/lightdm"}}
+	gdm     = displayManager{Name: "gdm", Paths: []string{"/etc/gdm3", "/etc/gdm", "/usr/sbin/gdm3", "/usr/sbin/gdm"}}
+	sddm    = displayManager{Name: "sddm", Paths: []string{"/etc/sddm.conf", "/etc/sddm.conf.d", "/usr/bin/sddm"}}
+)
+
+// installedDisplayManagers returns all the display managers that are installed on the machine.
+func installedDisplayManagers() []displayManager {
+	installed := []displayManager{}
+	for _, dm := range []displayManager{lightDM, gdm, sddm} {
+		for _, path := range dm.Paths {
+			if _, err := os.Stat(path); err == nil {
+				installed = append(installed, dm)
+				break
+			}
+		}
+	}
+
+	return installed
+}
+
+// DisplayManagerLogin is a script that disables guest sessions and automatic login on any
+// display manager installed on the machine, and hides the user list on the login screen.
+type DisplayManagerLogin struct {
+}
+
+func (s *DisplayManagerLogin) Name() string {
+	return "dmlogin"
+}
+
+func (s *DisplayManagerLogin) Description() string {
+	return "Disables guest sessions and automatic login on display managers."
+}
+
+func (s *DisplayManagerLogin) RunOnLinux() error {
+	installed := installedDisplayManagers()
+	if len(installed) == 0 {
+		logger.Info("No supported display manager (LightDM, GDM, SDDM) is installed")
+		return nil
+	}
+
+	for _, dm := range installed {
+		logger.Infof("Configuring %s", dm.Name)
+
+		var err error
+		switch dm.Name {
+		case lightDM.Name:
+			err = s.configureLightDM()
+		case gdm.Name:
+			err = s.configureGDM()
+		case sddm.Name:
+			err = s.configureSDDM()
+		}
+
+		if err != nil {
+			return fmt.Errorf("unable to configure %s: %s", dm.Name, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (s *DisplayManagerLogin) configureLightDM() error {
+	// Drop-in files are loaded before lightdm.conf, so any autologin set in them has to be cleared too.
+	dropIns, _ := filepath.Glob("/etc/lightdm/lightdm.conf.d/*.conf")
+	sort.Strings(dropIns)
+	for _, file := range dropIns {
+		f, err := utils.LoadINIFile(file)
+		if err != nil {
+			return err
+		}
+
+		for _, section := range []string{"Seat:*", "SeatDefaults"} {
+			if v, ok := f.Get(section, "autologin-user"); ok && v != "" {
+				setINIOption(f, section, "autologin-user", "")
+			}
+
+			if v, ok := f.Get(section, "allow-guest"); ok && v != "false" {
+				setINIOption(f, section, "allow-guest", "false")
+			}
+		}
+
+		if err := f.Save(); err != nil {
+			return err
+		}
+	}
+
+	f, err := utils.LoadINIFile("/etc/lightdm/lightdm.conf")
+	if err != nil {
+		return err
+	}
+
+	// Older versions of LightDM use [SeatDefaults] instead of [Seat:*].
+	section := "Seat:*"
+	if f.HasSection("SeatDefaults") && !f.HasSection(section) {
+		section = "SeatDefaults"
+	}
+
+	setINIOption(f, section, "allow-guest", "false")
+	setINIOption(f, section, "autologin-guest", "false")
+	setINIOption(f, section, "autologin-user", "")
+	setINIOption(f, section, "autologin-user-timeout", "0")
+	setINIOption(f, section, "greeter-hide-users", "true")
+	setINIOption(f, section, "greeter-show-manual-login", "true")
+
+	return f.Save()
+}
+
+func (s *DisplayManagerLogin) configureGDM() error {
+	file := "/etc/gdm3/custom.conf"
+	if _, err := os.Stat("/etc/gdm3"); os.IsNotExist(err) {
+		file = "/etc/gdm/custom.conf"
+	}
+
+	f, err := utils.LoadINIFile(file)
+	if err != nil {
+		return err
+	}
+
+	setINIOption(f, "daemon", "AutomaticLoginEnable", "false")
+	setINIOption(f, "daemon", "TimedLoginEnable", "false")
+	for _, key := range []string{"AutomaticLogin", "TimedLogin"} {
+		if f.Delete("daemon", key) {
+			logger.Warnf("%s: removed %s from [daemon]", file, key)
+		}
+	}
+
+	if err := f.Save(); err != nil {
+		return err
+	}
+
+	// GDM does not support guest sessions, but the user list is hidden through dconf.
+	return setGDMLoginScreenOptions("00-osharden-login-screen", map[string]string{
+		"disable-user-list": "true",
+	})
+}
+
+func (s *DisplayManagerLogin) configureSDDM() error {
+	files, _ := filepath.Glob("/etc/sddm.conf.d/*.conf")
+	sort.Strings(files)
+	files = append(files, "/etc/sddm.conf")
+
+	for _, file := range files {
+		f, err := utils.LoadINIFile(file)
+		if err != nil {
+			return err
+		}
+
+		// SDDM does not support guest sessions, so only autologin has to be disabled.
+		if v, ok := f.Get("Autologin", "User"); (ok && v != "") || file == "/etc/sddm.conf" {
+			setINIOption(f, "Autologin", "User", "")
+			setINIOption(f, "Autologin", "Session", "")
+			setINIOption(f, "Autologin", "Relogin", "false")
+		}
+
+		if err := f.Save(); err != nil {
+			return err
+		}
+	}
+
+	logger.Warn("SDDM can only hide the user list through its theme, so it has been left unchanged")
+	return nil
+}
+
+// setGDMLoginScreenOptions writes the given options for the GDM login screen to a dconf keyfile, and
+// updates the dconf database.
+func setGDMLoginScreenOptions(name string, opts map[string]string) error {
+	profile := "/etc/dconf/profile/gdm"
+	if _, err := os.Stat(profile); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(profile), 0755); err != nil {
+			return fmt.Errorf("unable to create %s: %s", filepath.Dir(profile), err.Error())
+		}
+
+		if err := utils.WriteFile(profile, []byte("user-db:user\nsystem-db:gdm\nfile-db:/usr/share/gdm/greeter-dconf-defaults\n"), 0644); err != nil {
+			return fmt.Errorf("unable to write to %s: %s", profile, err.Error())
+		}
+	}
+
+	if err := os.MkdirAll("/etc/dconf/db/gdm.d", 0755); err != nil {
+		return fmt.Errorf("unable to create /etc/dconf/db/gdm.d: %s", err.Error())
+	}
+
+	f, err := utils.LoadINIFile(filepath.Join("/etc/dconf/db/gdm.d", name))
+	if err != nil {
+		return err
+	}
+
+	keys := []string{}
+	for key := range opts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		setINIOption(f, "org/gnome/login-screen", key, opts[key])
+	}
+
+	if !f.Changed() {
+		return nil
+	}
+
+	if err := f.Save(); err != nil {
+		return err
+	}
+
+	if err := RunCommand("dconf update"); err != nil {
+		return fmt.Errorf("unable to update the dconf database: %s", err.Error())
+	}
+
+	return nil
+}
+
+// setINIOption sets an option in the INI file, and logs the change if the value was different.
+func setINIOption(f *utils.INIFile, section, key, value string) {
+	old, ok := f.Set(section, key, value)
+	if ok && old == value {
+		return
+	}
+
+	if !ok {
+		old = "(unset)"
+	}
+
+	logger.Warnf("%s: [%s] %s changed from %q to %q", f.Path(), section, key, old, value)
+}
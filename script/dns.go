@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/ethaniccc/simple-osharden/prompts"
+	"github.com/ethaniccc/simple-osharden/utils"
 )
 
 func init() {
@@ -55,7 +56,7 @@ func (s *UpdateDNS) RunOnLinux() error {
 	// Ask the user if they want to add additional DNS servers.
 	if !prompts.Confirm("Would you like to add new DNS servers?") {
 		// Write the new data to the file, since DNS servers could have been removed.
-		if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		if err := utils.WriteFile(file, []byte(data), 0644); err != nil {
 			return fmt.Errorf("unable to write /etc/resolv.conf: %s", err.Error())
 		}
 
@@ -83,7 +84,7 @@ func (s *UpdateDNS) RunOnLinux() error {
 		data += fmt.Sprintf("nameserver %s\n", newServer)
 	}
 
-	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+	if err := utils.WriteFile(file, []byte(data), 0644); err != nil {
 		return fmt.Errorf("unable to write /etc/resolv.conf: %s", err.Error())
 	}
 
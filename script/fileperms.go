@@ -0,0 +1,176 @@
+package script
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethaniccc/simple-osharden/prompts"
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+func init() {
+	RegisterScript(&FilePermissions{})
+}
+
+// filePermission is the ownership and mode that a critical system file is expected to have.
+type filePermission struct {
+	// Pattern is the path of the file, which may be a glob pattern.
+	Pattern string
+	// Owner is the name of the user that should own the file.
+	Owner string
+	// Groups are the names of the groups that may own the file. When fixing the file, the first
+	// group that exists on the machine is used.
+	Groups []string
+	// Mode is the most permissive mode the file is allowed to have.
+	Mode os.FileMode
+}
+
+// criticalFilePermissions is the baseline of permissions that are checked by FilePermissions.
+var criticalFilePermissions = []filePermission{
+	{Pattern: "/etc/passwd", Owner: "root", Groups: []string{"root"}, Mode: 0644},
+	{Pattern: "/etc/passwd-", Owner: "root", Groups: []string{"root"}, Mode: 0600},
+	{Pattern: "/etc/group", Owner: "root", Groups: []string{"root"}, Mode: 0644},
+	{Pattern: "/etc/group-", Owner: "root", Groups: []string{"root"}, Mode: 0600},
+	{Pattern: "/etc/shadow", Owner: "root", Groups: []string{"shadow", "root"}, Mode: 0640},
+	{Pattern: "/etc/shadow-", Owner: "root", Groups: []string{"shadow", "root"}, Mode: 0640},
+	{Pattern: "/etc/gshadow", Owner: "root", Groups: []string{"shadow", "root"}, Mode: 0640},
+	{Pattern: "/etc/gshadow-", Owner: "root", Groups: []string{"shadow", "root"}, Mode: 0640},
+	{Pattern: "/etc/sudoers", Owner: "root", Groups: []string{"root"}, Mode: 0440},
+	{Pattern: "/etc/sudoers.d/*", Owner: "root", Groups: []string{"root"}, Mode: 0440},
+	{Pattern: "/etc/ssh/sshd_config", Owner: "root", Groups: []string{"root"}, Mode: 0600},
+	{Pattern: "/etc/ssh/sshd_config.d/*", Owner: "root", Groups: []string{"root"}, Mode: 0600},
+	{Pattern: "/etc/ssh/ssh_host_*_key", Owner: "root", Groups: []string{"root", "ssh_keys"}, Mode: 0640},
+	{Pattern: "/etc/ssh/ssh_host_*_key.pub", Owner: "root", Groups: []string{"root"}, Mode: 0644},
+	{Pattern: "/boot/grub/grub.cfg", Owner: "root", Groups: []string{"root"}, Mode: 0600},
+	{Pattern: "/boot/grub2/grub.cfg", Owner: "root", Groups: []string{"root"}, Mode: 0600},
+	{Pattern: "/boot/grub2/user.cfg", Owner: "root", Groups: []string{"root"}, Mode: 0600},
+	{Pattern: "/etc/crontab", Owner: "root", Groups: []string{"root"}, Mode: 0600},
+	{Pattern: "/etc/cron.hourly", Owner: "root", Groups: []string{"root"}, Mode: 0700},
+	{Pattern: "/etc/cron.daily", Owner: "root", Groups: []string{"root"}, Mode: 0700},
+	{Pattern: "/etc/cron.weekly", Owner: "root", Groups: []string{"root"}, Mode: 0700},
+	{Pattern: "/etc/cron.monthly", Owner: "root", Groups: []string{"root"}, Mode: 0700},
+	{Pattern: "/etc/cron.d", Owner: "root", Groups: []string{"root"}, Mode: 0700},
+	{Pattern: "/etc/cron.allow", Owner: "root", Groups: []string{"root"}, Mode: 0640},
+	{Pattern: "/etc/at.allow", Owner: "root", Groups: []string{"root"}, Mode: 0640},
+}
+
+// FilePermissions is a script that checks the ownership and mode of critical system files against a
+// baseline, and fixes any files that do not match it.
+type FilePermissions struct {
+}
+
+func (s *FilePermissions) Name() string {
+	return "fileperms"
+}
+
+func (s *FilePermissions) Description() string {
+	return "Enforces the permissions of critical system files."
+}
+
+func (s *FilePermissions) RunOnLinux() error {
+	users, err := utils.ReadPasswd("/etc/passwd")
+	if err != nil {
+		return err
+	}
+
+	groups, err := utils.ReadGroup("/etc/group")
+	if err != nil {
+		return err
+	}
+
+	uids, gids := map[string]int{}, map[string]int{}
+	for _, u := range users {
+		uids[u.Name] = u.UID
+	}
+	for _, g := range groups {
+		gids[g.Name] = g.GID
+	}
+
+	// Audit every file first, so the user can see everything that is wrong before anything is changed.
+	type mismatch struct {
+		path    string
+		perm    filePermission
+		info    os.FileInfo
+		finding *Finding
+	}
+
+	report := &Report{}
+	mismatches := []mismatch{}
+	for _, perm := range criticalFilePermissions {
+		matches, _ := filepath.Glob(perm.Pattern)
+		for _, path := range matches {
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+
+			reasons := []string{}
+			uid, gid, ok := utils.FileOwner(info)
+			if ok && uid != uids[perm.Owner] {
+				reasons = append(reasons, fmt.Sprintf("owned by uid %d instead of %s", uid, perm.Owner))
+			}
+
+			if ok && !s.hasGroup(gid, perm.Groups, gids) {
+				reasons = append(reasons, fmt.Sprintf("group is gid %d instead of %s", gid, strings.Join(perm.Groups, " or ")))
+			}
+
+			if extra := info.Mode().Perm() &^ perm.Mode; extra != 0 {
+				reasons = append(reasons, fmt.Sprintf("mode %04o is more permissive than %04o", info.Mode().Perm(), perm.Mode))
+			}
+
+			if len(reasons) == 0 {
+				continue
+			}
+
+			mismatches = append(mismatches, mismatch{
+				path:    path,
+				perm:    perm,
+				info:    info,
+				finding: report.Flag(path, strings.Join(reasons, ", ")),
+			})
+		}
+	}
+
+	if len(mismatches) == 0 || !prompts.Confirm(fmt.Sprintf("Should the permissions of the %d file(s) be fixed?", len(mismatches))) {
+		report.Summarize()
+		return nil
+	}
+
+	for _, m := range mismatches {
+		gid := -1
+		for _, g := range m.perm.Groups {
+			if v, ok := gids[g]; ok {
+				gid = v
+				break
+			}
+		}
+
+		if err := os.Chown(m.path, uids[m.perm.Owner], gid); err != nil {
+			logger.Errorf("unable to change the owner of %s: %s", m.path, err.Error())
+			continue
+		}
+
+		if err := os.Chmod(m.path, withSpecialBits(m.info.Mode(), m.info.Mode().Perm()&m.perm.Mode)); err != nil {
+			logger.Errorf("unable to change the mode of %s: %s", m.path, err.Error())
+			continue
+		}
+
+		m.finding.Fixed = true
+	}
+
+	report.Summarize()
+	return nil
+}
+
+// hasGroup returns true if the gid belongs to any of the given group names.
+func (s *FilePermissions) hasGroup(gid int, names []string, gids map[string]int) bool {
+	for _, name := range names {
+		if v, ok := gids[name]; ok && v == gid {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,99 @@
+package script
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+// skippedFSTypes are filesystem types that are never walked, because they are either pseudo
+// filesystems provided by the kernel, network filesystems or read-only images such as snaps, whose
+// files can not be changed.
+var skippedFSTypes = map[string]bool{
+	"proc": true, "sysfs": true, "devtmpfs": true, "devpts": true, "cgroup": true, "cgroup2": true,
+	"securityfs": true, "debugfs": true, "tracefs": true, "pstore": true, "bpf": true, "configfs": true,
+	"fusectl": true, "mqueue": true, "hugetlbfs": true, "autofs": true, "binfmt_misc": true,
+	"efivarfs": true, "rpc_pipefs": true, "nsfs": true,
+	"nfs": true, "nfs4": true, "cifs": true, "smb3": true, "smbfs": true, "9p": true, "ceph": true,
+	"glusterfs": true, "fuse.sshfs": true, "afs": true,
+	"squashfs": true, "iso9660": true,
+}
+
+// skippedMountPoints returns the mount points of all the filesystems that should not be walked.
+func skippedMountPoints() map[string]bool {
+	skipped := map[string]bool{}
+	mounts, err := utils.ReadMounts("/proc/self/mounts")
+	if err != nil {
+		logger.Warnf("unable to read mounts, network filesystems will not be skipped: %s", err.Error())
+		return skipped
+	}
+
+	for _, m := range mounts {
+		if skippedFSTypes[m.FSType] {
+			skipped[m.MountPoint] = true
+		}
+	}
+
+	return skipped
+}
+
+// walkLocalFiles walks the given root, skipping pseudo and network filesystems and any path in
+// exclude. The given function is called for every file and directory that is found.
+func walkLocalFiles(root string, exclude []string, fn func(path string, info os.FileInfo)) {
+	skipped := skippedMountPoints()
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable directories are skipped instead of stopping the walk.
+			return nil
+		}
+
+		if isExcludedPath(path, exclude) || skipped[path] {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		fn(path, info)
+		return nil
+	})
+}
+
+// isExcludedPath returns true if the path is in, or is one of, the excluded paths.
+func isExcludedPath(path string, exclude []string) bool {
+	for _, e := range exclude {
+		if path == e || strings.HasPrefix(path, strings.TrimSuffix(e, "/")+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hashFile returns the hex encoded SHA-256 hash of the given file.
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,51 @@
+package script
+
+// Finding is an issue that an audit script found on the machine.
+type Finding struct {
+	// Path is the file (or other resource) that the finding is about.
+	Path string
+	// Reason describes why the path was flagged.
+	Reason string
+	// Fixed is true if the issue has been resolved since it was found.
+	Fixed bool
+}
+
+// Report is a collection of findings produced by an audit script.
+type Report struct {
+	Findings []*Finding
+}
+
+// Flag adds a new finding to the report and logs it.
+func (r *Report) Flag(path, reason string) *Finding {
+	f := &Finding{Path: path, Reason: reason}
+	r.Findings = append(r.Findings, f)
+	logger.Warnf("%s: %s", path, reason)
+
+	return f
+}
+
+// Unfixed returns all the findings in the report that have not been fixed.
+func (r *Report) Unfixed() []*Finding {
+	unfixed := []*Finding{}
+	for _, f := range r.Findings {
+		if !f.Fixed {
+			unfixed = append(unfixed, f)
+		}
+	}
+
+	return unfixed
+}
+
+// Summarize logs how many findings were found, and how many of them were fixed.
+func (r *Report) Summarize() {
+	if len(r.Findings) == 0 {
+		logger.Info("No issues were found")
+		return
+	}
+
+	unfixed := r.Unfixed()
+	logger.Infof("%d issue(s) found, %d fixed", len(r.Findings), len(r.Findings)-len(unfixed))
+	for _, f := range unfixed {
+		logger.Warnf("Unresolved: %s: %s", f.Path, f.Reason)
+	}
+}
@@ -0,0 +1,235 @@
+package script
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/ethaniccc/simple-osharden/prompts"
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+func init() {
+	RegisterScript(&FilesystemAudit{})
+}
+
+// fsIssue is a kind of issue that FilesystemAudit looks for.
+type fsIssue int
+
+const (
+	fsIssueWorldWritableFile fsIssue = iota
+	fsIssueWorldWritableDir
+	fsIssueWritablePath
+	fsIssueNoOwner
+	fsIssueNoGroup
+)
+
+// fsIssueDescriptions describes each kind of issue that FilesystemAudit looks for.
+var fsIssueDescriptions = map[fsIssue]string{
+	fsIssueWorldWritableFile: "world-writable file",
+	fsIssueWorldWritableDir:  "world-writable directory without the sticky bit",
+	fsIssueWritablePath:      "world-writable entry in a PATH directory",
+	fsIssueNoOwner:           "owner does not exist in /etc/passwd",
+	fsIssueNoGroup:           "group does not exist in /etc/group",
+}
+
+// fsCandidate is a file that FilesystemAudit found an issue with.
+type fsCandidate struct {
+	issue   fsIssue
+	path    string
+	info    os.FileInfo
+	finding *Finding
+}
+
+// FilesystemAudit is a script that scans the filesystem for world-writable files and directories,
+// and files that are not owned by a valid user or group. The user is able to fix the issues that
+// are found in bulk or one by one.
+type FilesystemAudit struct {
+}
+
+func (s *FilesystemAudit) Name() string {
+	return "fsaudit"
+}
+
+func (s *FilesystemAudit) Description() string {
+	return "Finds world-writable, unowned and orphaned files."
+}
+
+func (s *FilesystemAudit) RunOnLinux() error {
+	users, err := utils.ReadPasswd("/etc/passwd")
+	if err != nil {
+		return err
+	}
+
+	groups, err := utils.ReadGroup("/etc/group")
+	if err != nil {
+		return err
+	}
+
+	uids, gids := map[int]bool{}, map[int]bool{}
+	for _, u := range users {
+		uids[u.UID] = true
+	}
+	for _, g := range groups {
+		gids[g.GID] = true
+	}
+
+	roots := strings.Fields(prompts.RawResponseWithDefaultPrompt("Which directories should be scanned? (default is /)", "/"))
+	exclude := strings.Fields(prompts.RawResponseWithDefaultPrompt("Which paths should be excluded? (default is /proc /sys /dev /run /snap)", "/proc /sys /dev /run /snap"))
+
+	logger.Info("Scanning the filesystem, this may take a while...")
+	candidates := make(chan fsCandidate)
+	go func() {
+		s.scan(roots, exclude, uids, gids, candidates)
+		close(candidates)
+	}()
+
+	// Findings are collected from a single goroutine so the report does not need to be locked.
+	report := &Report{}
+	found := map[fsIssue][]fsCandidate{}
+	for c := range candidates {
+		c.finding = report.Flag(c.path, fsIssueDescriptions[c.issue])
+		found[c.issue] = append(found[c.issue], c)
+	}
+
+	s.fix(found[fsIssueWorldWritableFile], "remove world write access from", func(c fsCandidate) error {
+		return os.Chmod(c.path, withSpecialBits(c.info.Mode(), c.info.Mode().Perm()&^0002))
+	})
+	s.fix(found[fsIssueWorldWritableDir], "add the sticky bit to", func(c fsCandidate) error {
+		return os.Chmod(c.path, withSpecialBits(c.info.Mode(), c.info.Mode().Perm())|os.ModeSticky)
+	})
+	s.fix(found[fsIssueWritablePath], "remove world write access from", func(c fsCandidate) error {
+		return os.Chmod(c.path, withSpecialBits(c.info.Mode(), c.info.Mode().Perm()&^0002))
+	})
+	s.fix(found[fsIssueNoOwner], "change the owner to root of", func(c fsCandidate) error {
+		return os.Lchown(c.path, 0, -1)
+	})
+	s.fix(found[fsIssueNoGroup], "change the group to root of", func(c fsCandidate) error {
+		return os.Lchown(c.path, -1, 0)
+	})
+
+	report.Summarize()
+	return nil
+}
+
+// scan walks the given roots concurrently, and sends any files with issues to the given channel.
+func (s *FilesystemAudit) scan(roots, exclude []string, uids, gids map[int]bool, candidates chan<- fsCandidate) {
+	pathDirs := map[string]bool{}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		pathDirs[dir] = true
+	}
+
+	check := func(path string, info os.FileInfo) {
+		if uid, gid, ok := utils.FileOwner(info); ok {
+			if !uids[uid] {
+				candidates <- fsCandidate{issue: fsIssueNoOwner, path: path, info: info}
+			}
+
+			if !gids[gid] {
+				candidates <- fsCandidate{issue: fsIssueNoGroup, path: path, info: info}
+			}
+		}
+
+		// Symlinks are always 0777, so only the permissions of their targets matter. PATH directories
+		// and their contents are checked separately.
+		if info.Mode()&os.ModeSymlink != 0 || info.Mode().Perm()&0002 == 0 || pathDirs[path] || pathDirs[filepath.Dir(path)] {
+			return
+		}
+
+		if info.Mode().IsRegular() {
+			candidates <- fsCandidate{issue: fsIssueWorldWritableFile, path: path, info: info}
+		} else if info.IsDir() && info.Mode()&os.ModeSticky == 0 {
+			candidates <- fsCandidate{issue: fsIssueWorldWritableDir, path: path, info: info}
+		}
+	}
+
+	// Each top level directory is walked in its own goroutine, limited to one per CPU.
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, runtime.NumCPU())
+	for _, root := range roots {
+		info, err := os.Lstat(root)
+		if err != nil {
+			logger.Errorf("unable to scan %s: %s", root, err.Error())
+			continue
+		}
+
+		if !info.IsDir() || isExcludedPath(root, exclude) {
+			walkLocalFiles(root, exclude, check)
+			continue
+		}
+
+		check(root, info)
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			logger.Errorf("unable to scan %s: %s", root, err.Error())
+			continue
+		}
+
+		for _, entry := range entries {
+			wg.Add(1)
+			go func(path string) {
+				defer wg.Done()
+				sem <- struct{}{}
+				defer func() { <-sem }()
+
+				walkLocalFiles(path, exclude, check)
+			}(filepath.Join(root, entry.Name()))
+		}
+	}
+
+	// Check the PATH directories themselves and the files directly inside them, even if they are excluded.
+	for dir := range pathDirs {
+		paths := []string{dir}
+		if entries, err := os.ReadDir(dir); err == nil {
+			for _, entry := range entries {
+				paths = append(paths, filepath.Join(dir, entry.Name()))
+			}
+		}
+
+		for _, path := range paths {
+			info, err := os.Lstat(path)
+			if err != nil || info.Mode()&os.ModeSymlink != 0 || info.Mode().Perm()&0002 == 0 {
+				continue
+			}
+
+			candidates <- fsCandidate{issue: fsIssueWritablePath, path: path, info: info}
+		}
+	}
+
+	wg.Wait()
+}
+
+// fix asks the user if the given candidates should be fixed in bulk or one by one, and fixes them
+// with the given function.
+func (s *FilesystemAudit) fix(candidates []fsCandidate, action string, fn func(c fsCandidate) error) {
+	if len(candidates) == 0 {
+		return
+	}
+
+	bulk := prompts.Confirm(fmt.Sprintf("Would you like to %s all %d %s(s)?", action, len(candidates), fsIssueDescriptions[candidates[0].issue]))
+	if !bulk && !prompts.Confirm("Would you like to fix them one by one?") {
+		return
+	}
+
+	for _, c := range candidates {
+		if !bulk && !prompts.Confirm(fmt.Sprintf("Would you like to %s %s?", action, c.path)) {
+			continue
+		}
+
+		if err := fn(c); err != nil {
+			logger.Errorf("unable to fix %s: %s", c.path, err.Error())
+			continue
+		}
+
+		c.finding.Fixed = true
+	}
+}
+
+// withSpecialBits returns the given permissions with the setuid, setgid and sticky bits from the
+// original mode, since os.Chmod would clear them otherwise.
+func withSpecialBits(original, perm os.FileMode) os.FileMode {
+	return perm | original&(os.ModeSetuid|os.ModeSetgid|os.ModeSticky)
+}
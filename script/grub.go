@@ -0,0 +1,238 @@
+package script
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethaniccc/simple-osharden/prompts"
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+const (
+	// grubCustomFile is the grub.d script that the GRUB password is written to.
+	grubCustomFile = "/etc/grub.d/40_custom"
+	// grubLinuxFile is the grub.d script that generates the boot entries for the installed kernels.
+	grubLinuxFile = "/etc/grub.d/10_linux"
+
+	// grubPBKDF2Iterations and grubPBKDF2Length are the same as the defaults of grub-mkpasswd-pbkdf2.
+	grubPBKDF2Iterations = 10000
+	grubPBKDF2Length     = 64
+
+	grubBlockStart = "# BEGIN Simple-OSHarden password"
+	grubBlockEnd   = "# END Simple-OSHarden password"
+)
+
+func init() {
+	RegisterScript(&GrubPassword{})
+}
+
+// GrubPassword is a script that stops anyone at the console from editing the boot entries or using
+// the GRUB shell without a password, which would otherwise let them boot into a root shell.
+type GrubPassword struct {
+}
+
+func (s *GrubPassword) Name() string {
+	return "grubpassword"
+}
+
+func (s *GrubPassword) Description() string {
+	return "Sets a GRUB password and locks down the GRUB config."
+}
+
+func (s *GrubPassword) RunOnLinux() error {
+	if _, err := os.Stat(grubCustomFile); os.IsNotExist(err) {
+		return fmt.Errorf("%s does not exist, GRUB 2 does not seem to be installed", grubCustomFile)
+	}
+
+	user := prompts.RawResponseWithDefaultPrompt("What should the GRUB superuser be called? (default is root)", "root")
+	password := prompts.SecretPrompt("What should the GRUB password be?")
+	if password == "" {
+		return fmt.Errorf("the GRUB password can not be empty")
+	}
+
+	if prompts.SecretPrompt("Please enter the GRUB password again.") != password {
+		return fmt.Errorf("the passwords do not match")
+	}
+
+	hash, err := grubPasswordHash(password)
+	if err != nil {
+		return err
+	}
+
+	block := []string{
+		grubBlockStart,
+		fmt.Sprintf("set superusers=%q", user),
+		fmt.Sprintf("password_pbkdf2 %s %s", user, hash),
+		grubBlockEnd,
+	}
+	if err := s.writeCustomBlock(block); err != nil {
+		return err
+	}
+
+	if prompts.Confirm("Should the normal boot entries be bootable without the password (editing them will still need it)?") {
+		if err := s.setUnrestricted(); err != nil {
+			logger.Errorf("unable to make the boot entries unrestricted: %s", err.Error())
+		}
+	}
+
+	cfg := ""
+	for _, path := range []string{"/boot/grub/grub.cfg", "/boot/grub2/grub.cfg"} {
+		if _, err := os.Stat(path); err == nil {
+			cfg = path
+			break
+		}
+	}
+
+	if cfg == "" {
+		return fmt.Errorf("unable to find grub.cfg")
+	}
+
+	if distroFamily() == "debian" {
+		err = RunCommand("update-grub")
+	} else {
+		err = RunCommandWithArgs("grub2-mkconfig", "-o", cfg)
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to regenerate %s: %s", cfg, err.Error())
+	}
+
+	// The config contains the password hash, so only root should be able to read it.
+	if err := os.Chown(cfg, 0, 0); err != nil {
+		return fmt.Errorf("unable to change the owner of %s: %s", cfg, err.Error())
+	}
+
+	if err := os.Chmod(cfg, 0600); err != nil {
+		return fmt.Errorf("unable to change the mode of %s: %s", cfg, err.Error())
+	}
+
+	buffer, err := os.ReadFile(cfg)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %s", cfg, err.Error())
+	}
+
+	if !strings.Contains(string(buffer), "password_pbkdf2 "+user) {
+		return fmt.Errorf("the password is not in %s, check that %s is executable", cfg, grubCustomFile)
+	}
+
+	logger.Infof("The GRUB password for %s has been set", user)
+	return nil
+}
+
+// writeCustomBlock replaces the lines added to 40_custom by an earlier run with the given lines, or
+// adds them to the end of the file if there are none.
+func (s *GrubPassword) writeCustomBlock(block []string) error {
+	buffer, err := os.ReadFile(grubCustomFile)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %s", grubCustomFile, err.Error())
+	}
+
+	lines := []string{}
+	inBlock := false
+	for _, line := range strings.Split(strings.TrimSuffix(string(buffer), "\n"), "\n") {
+		switch {
+		case line == grubBlockStart:
+			inBlock = true
+		case line == grubBlockEnd:
+			inBlock = false
+		case !inBlock:
+			lines = append(lines, line)
+		}
+	}
+
+	lines = append(lines, block...)
+	if err := utils.WriteFile(grubCustomFile, []byte(strings.Join(lines, "\n")+"\n"), 0700); err != nil {
+		return fmt.Errorf("unable to write to %s: %s", grubCustomFile, err.Error())
+	}
+
+	// grub-mkconfig only runs the scripts that are executable, and the hash should not be readable
+	// by other users.
+	if err := os.Chmod(grubCustomFile, 0700); err != nil {
+		return fmt.Errorf("unable to change the mode of %s: %s", grubCustomFile, err.Error())
+	}
+
+	return nil
+}
+
+// setUnrestricted adds --unrestricted to the boot entries generated by 10_linux, so that anyone can
+// boot them, while editing them or using the GRUB shell still needs the password.
+func (s *GrubPassword) setUnrestricted() error {
+	buffer, err := os.ReadFile(grubLinuxFile)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %s", grubLinuxFile, err.Error())
+	}
+
+	if strings.Contains(string(buffer), "--unrestricted") {
+		return nil
+	}
+
+	lines := strings.Split(string(buffer), "\n")
+	changed := false
+	for i, line := range lines {
+		// Later assignments add to ${CLASS}, so only the first assignment needs the option.
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, `CLASS="`) || strings.Contains(trimmed, "${CLASS}") {
+			continue
+		}
+
+		lines[i] = strings.Replace(line, `CLASS="`, `CLASS="--unrestricted `, 1)
+		changed = true
+	}
+
+	if !changed {
+		return fmt.Errorf("unable to find the boot entry options in %s", grubLinuxFile)
+	}
+
+	if err := utils.WriteFile(grubLinuxFile, []byte(strings.Join(lines, "\n")), 0755); err != nil {
+		return fmt.Errorf("unable to write to %s: %s", grubLinuxFile, err.Error())
+	}
+
+	logger.Warnf("%s is owned by the GRUB package, so this has to be done again after GRUB is upgraded", grubLinuxFile)
+	return nil
+}
+
+// grubPasswordHash returns the password hashed in the same format as grub-mkpasswd-pbkdf2.
+func grubPasswordHash(password string) (string, error) {
+	salt := make([]byte, grubPBKDF2Length)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("unable to generate a salt: %s", err.Error())
+	}
+
+	key := pbkdf2SHA512([]byte(password), salt, grubPBKDF2Iterations, grubPBKDF2Length)
+	return fmt.Sprintf("grub.pbkdf2.sha512.%d.%X.%X", grubPBKDF2Iterations, salt, key), nil
+}
+
+// pbkdf2SHA512 derives a key of the given length from the password with PBKDF2 (RFC 8018), using
+// HMAC-SHA512 as the pseudorandom function.
+func pbkdf2SHA512(password, salt []byte, iterations, length int) []byte {
+	prf := hmac.New(sha512.New, password)
+	key := make([]byte, 0, length)
+	block := make([]byte, 4)
+	for i := uint32(1); len(key) < length; i++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(block, i)
+		prf.Write(block)
+		u := prf.Sum(nil)
+
+		t := make([]byte, len(u))
+		copy(t, u)
+		for n := 1; n < iterations; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+
+		key = append(key, t...)
+	}
+
+	return key[:length]
+}
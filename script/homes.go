@@ -0,0 +1,143 @@
+package script
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ethaniccc/simple-osharden/prompts"
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+func init() {
+	RegisterScript(&HomePermissions{})
+}
+
+// dotfileRule describes the permissions a file in a user's home directory is allowed to have.
+type dotfileRule struct {
+	// Name is the name of the file relative to the home directory.
+	Name string
+	// Forbidden are the permission bits the file must not have.
+	Forbidden os.FileMode
+	// Prohibited is true if the file should not exist at all.
+	Prohibited bool
+}
+
+// dotfileRules are the files in each home directory that are checked by HomePermissions.
+var dotfileRules = []dotfileRule{
+	{Name: ".ssh", Forbidden: 0077},
+	{Name: ".bashrc", Forbidden: 0022},
+	{Name: ".profile", Forbidden: 0022},
+	{Name: ".forward", Forbidden: 0022},
+	{Name: ".netrc", Forbidden: 0077, Prohibited: true},
+	{Name: ".rhosts", Forbidden: 0077, Prohibited: true},
+}
+
+// HomePermissions is a script that makes sure every user's home directory is owned by them
+// and cannot be written to by anyone else. Sensitive dotfiles in each home directory are also
+// checked for unsafe permissions.
+type HomePermissions struct {
+}
+
+func (s *HomePermissions) Name() string {
+	return "homeperms"
+}
+
+func (s *HomePermissions) Description() string {
+	return "Checks the permissions of home directories and dotfiles."
+}
+
+func (s *HomePermissions) RunOnLinux() error {
+	users, err := humanUsers()
+	if err != nil {
+		return err
+	}
+
+	fix := prompts.Confirm("Should unsafe permissions be fixed automatically?")
+	report := &Report{}
+	for _, user := range users {
+		info, err := os.Lstat(user.Home)
+		if os.IsNotExist(err) {
+			report.Flag(user.Home, fmt.Sprintf("home directory of %s does not exist", user.Name))
+			continue
+		} else if err != nil {
+			logger.Errorf("unable to stat %s: %s", user.Home, err.Error())
+			continue
+		}
+
+		if !info.IsDir() {
+			report.Flag(user.Home, fmt.Sprintf("home directory of %s is not a directory", user.Name))
+			continue
+		}
+
+		s.checkOwner(user, user.Home, info, fix, report)
+		s.checkMode(user.Home, info, 0022, fix, report)
+
+		for _, rule := range dotfileRules {
+			path := filepath.Join(user.Home, rule.Name)
+			info, err := os.Lstat(path)
+			if err != nil || info.Mode()&os.ModeSymlink != 0 {
+				continue
+			}
+
+			if rule.Prohibited {
+				f := report.Flag(path, fmt.Sprintf("%s files are prohibited", rule.Name))
+				if fix && prompts.Confirm(fmt.Sprintf("Should %s be deleted?", path)) {
+					if err := os.Remove(path); err != nil {
+						logger.Errorf("unable to delete %s: %s", path, err.Error())
+					} else {
+						f.Fixed = true
+						continue
+					}
+				}
+			}
+
+			s.checkOwner(user, path, info, fix, report)
+			s.checkMode(path, info, rule.Forbidden, fix, report)
+		}
+	}
+
+	report.Summarize()
+	return nil
+}
+
+// checkOwner flags the path if it is not owned by the given user, and changes the owner if fix is true.
+func (s *HomePermissions) checkOwner(user utils.PasswdEntry, path string, info os.FileInfo, fix bool, report *Report) {
+	uid, _, ok := utils.FileOwner(info)
+	if !ok || uid == user.UID {
+		return
+	}
+
+	f := report.Flag(path, fmt.Sprintf("owned by uid %d instead of %s", uid, user.Name))
+	if !fix {
+		return
+	}
+
+	if err := os.Lchown(path, user.UID, user.GID); err != nil {
+		logger.Errorf("unable to change the owner of %s: %s", path, err.Error())
+		return
+	}
+
+	f.Fixed = true
+}
+
+// checkMode flags the path if it has any of the forbidden permission bits, and removes them if fix is true.
+func (s *HomePermissions) checkMode(path string, info os.FileInfo, forbidden os.FileMode, fix bool, report *Report) {
+	mode := info.Mode().Perm()
+	if mode&forbidden == 0 {
+		return
+	}
+
+	f := report.Flag(path, fmt.Sprintf("unsafe mode %04o", mode))
+	if !fix {
+		return
+	}
+
+	if err := os.Chmod(path, withSpecialBits(info.Mode(), mode&^forbidden)); err != nil {
+		logger.Errorf("unable to change the mode of %s: %s", path, err.Error())
+		return
+	}
+
+	logger.Infof("Changed the mode of %s to %04o", path, mode&^forbidden)
+	f.Fixed = true
+}
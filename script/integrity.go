@@ -0,0 +1,308 @@
+package script
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethaniccc/simple-osharden/prompts"
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+const (
+	// integrityDatabase is where the integrity baseline is stored. Only root can read it.
+	integrityDatabase = "/var/lib/osharden/integrity.json"
+	// integrityRules is the file that lists the paths included in and excluded from the baseline.
+	integrityRules = "/etc/osharden/integrity.rules"
+)
+
+// defaultIntegrityRules are used when the rules file does not exist. Lines starting with + are
+// included, and lines starting with - are excluded. Excludes may be glob patterns.
+var defaultIntegrityRules = []string{
+	"+/etc",
+	"+/bin",
+	"+/sbin",
+	"+/usr/bin",
+	"+/usr/sbin",
+	"+/boot",
+	"-/etc/mtab",
+	"-/etc/adjtime",
+	"-/etc/ld.so.cache",
+	"-/etc/resolv.conf",
+	"-/etc/osharden",
+	"-*.swp",
+}
+
+func init() {
+	RegisterScript(&IntegrityBaseline{})
+	RegisterScript(&IntegrityVerify{})
+}
+
+// integrityEntry is the recorded state of a single path in the integrity baseline.
+type integrityEntry struct {
+	Path   string      `json:"path"`
+	Size   int64       `json:"size"`
+	Mode   os.FileMode `json:"mode"`
+	UID    int         `json:"uid"`
+	GID    int         `json:"gid"`
+	SHA256 string      `json:"sha256,omitempty"`
+	Link   string      `json:"link,omitempty"`
+}
+
+// integrityBaseline is the database of entries that the filesystem is verified against.
+type integrityBaseline struct {
+	Created time.Time                 `json:"created"`
+	Rules   []string                  `json:"rules"`
+	Entries map[string]integrityEntry `json:"entries"`
+}
+
+// loadIntegrityRules returns the rules in the rules file, or the default rules if it does not exist.
+func loadIntegrityRules() ([]string, error) {
+	buffer, err := os.ReadFile(integrityRules)
+	if os.IsNotExist(err) {
+		return defaultIntegrityRules, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", integrityRules, err.Error())
+	}
+
+	rules := []string{}
+	for _, line := range strings.Split(string(buffer), "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-") {
+			rules = append(rules, line)
+		}
+	}
+
+	return rules, nil
+}
+
+// snapshotIntegrity records the current state of every path matched by the rules. Files are hashed in parallel.
+func snapshotIntegrity(rules []string) map[string]integrityEntry {
+	includes, excludes, patterns := []string{}, []string{}, []string{}
+	for _, rule := range rules {
+		path := rule[1:]
+		if rule[0] == '+' {
+			// Roots such as /bin may be symlinks on merged /usr systems, and symlinked roots are not walked.
+			if target, err := filepath.EvalSymlinks(path); err == nil {
+				path = target
+			}
+
+			includes = append(includes, path)
+		} else if strings.ContainsAny(path, "*?[") {
+			patterns = append(patterns, path)
+		} else {
+			excludes = append(excludes, path)
+		}
+	}
+
+	entries := map[string]integrityEntry{}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	files := make(chan integrityEntry)
+
+	// Start a worker per CPU to hash regular files.
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range files {
+				hash, err := hashFile(e.Path)
+				if err != nil {
+					logger.Warnf("unable to hash %s: %s", e.Path, err.Error())
+				}
+				e.SHA256 = hash
+
+				mu.Lock()
+				entries[e.Path] = e
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, root := range includes {
+		walkLocalFiles(root, excludes, func(path string, info os.FileInfo) {
+			for _, pattern := range patterns {
+				if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+					return
+				}
+
+				if ok, _ := filepath.Match(pattern, path); ok {
+					return
+				}
+			}
+
+			e := integrityEntry{Path: path, Size: info.Size(), Mode: info.Mode(), UID: -1, GID: -1}
+			if uid, gid, ok := utils.FileOwner(info); ok {
+				e.UID, e.GID = uid, gid
+			}
+
+			if info.Mode().IsRegular() {
+				files <- e
+				return
+			}
+
+			if info.Mode()&os.ModeSymlink != 0 {
+				e.Link, _ = os.Readlink(path)
+			}
+
+			mu.Lock()
+			entries[path] = e
+			mu.Unlock()
+		})
+	}
+
+	close(files)
+	wg.Wait()
+
+	return entries
+}
+
+// saveIntegrityBaseline writes the baseline to the database, which is only readable by root.
+func saveIntegrityBaseline(baseline integrityBaseline) error {
+	if err := os.MkdirAll(filepath.Dir(integrityDatabase), 0700); err != nil {
+		return fmt.Errorf("unable to create %s: %s", filepath.Dir(integrityDatabase), err.Error())
+	}
+
+	buffer, err := json.Marshal(baseline)
+	if err != nil {
+		return fmt.Errorf("unable to encode the integrity baseline: %s", err.Error())
+	}
+
+	if err := os.WriteFile(integrityDatabase, buffer, 0600); err != nil {
+		return fmt.Errorf("unable to write to %s: %s", integrityDatabase, err.Error())
+	}
+
+	// The file may have existed with a different owner or mode, so make sure only root can read it.
+	if err := os.Chown(integrityDatabase, 0, 0); err != nil {
+		return fmt.Errorf("unable to change the owner of %s: %s", integrityDatabase, err.Error())
+	}
+
+	return os.Chmod(integrityDatabase, 0600)
+}
+
+// IntegrityBaseline is a script that records the size, mode, owner and hash of important system
+// files, so IntegrityVerify can later detect if they were tampered with.
+type IntegrityBaseline struct {
+}
+
+func (s *IntegrityBaseline) Name() string {
+	return "fimbaseline"
+}
+
+func (s *IntegrityBaseline) Description() string {
+	return "Records a file integrity baseline of system files."
+}
+
+func (s *IntegrityBaseline) RunOnLinux() error {
+	if _, err := os.Stat(integrityDatabase); err == nil && !prompts.Confirm("An integrity baseline already exists. Should it be replaced?") {
+		return nil
+	}
+
+	rules, err := loadIntegrityRules()
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Recording the integrity baseline, this may take a while...")
+	baseline := integrityBaseline{Created: time.Now(), Rules: rules, Entries: snapshotIntegrity(rules)}
+	if err := saveIntegrityBaseline(baseline); err != nil {
+		return err
+	}
+
+	logger.Infof("Recorded %d entries to %s", len(baseline.Entries), integrityDatabase)
+	return nil
+}
+
+// IntegrityVerify is a script that compares the current state of the filesystem against the baseline
+// recorded by IntegrityBaseline, and reports any files that were added, removed or modified.
+type IntegrityVerify struct {
+}
+
+func (s *IntegrityVerify) Name() string {
+	return "fimverify"
+}
+
+func (s *IntegrityVerify) Description() string {
+	return "Verifies system files against the integrity baseline."
+}
+
+func (s *IntegrityVerify) RunOnLinux() error {
+	buffer, err := os.ReadFile(integrityDatabase)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no integrity baseline exists, run fimbaseline first")
+	} else if err != nil {
+		return fmt.Errorf("unable to read %s: %s", integrityDatabase, err.Error())
+	}
+
+	baseline := integrityBaseline{}
+	if err := json.Unmarshal(buffer, &baseline); err != nil {
+		return fmt.Errorf("unable to decode %s: %s", integrityDatabase, err.Error())
+	}
+
+	logger.Infof("Verifying against the baseline recorded at %s, this may take a while...", baseline.Created.Format(time.RFC1123))
+	current := snapshotIntegrity(baseline.Rules)
+
+	paths := []string{}
+	for path := range baseline.Entries {
+		paths = append(paths, path)
+	}
+	for path := range current {
+		if _, ok := baseline.Entries[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	report := &Report{}
+	for _, path := range paths {
+		old, existed := baseline.Entries[path]
+		now, exists := current[path]
+		if !existed {
+			report.Flag(path, "added")
+			continue
+		} else if !exists {
+			report.Flag(path, "removed")
+			continue
+		}
+
+		changes := []string{}
+		if old.Mode != now.Mode {
+			changes = append(changes, fmt.Sprintf("mode %s -> %s", old.Mode, now.Mode))
+		}
+
+		if old.UID != now.UID || old.GID != now.GID {
+			changes = append(changes, fmt.Sprintf("owner %d:%d -> %d:%d", old.UID, old.GID, now.UID, now.GID))
+		}
+
+		if old.Size != now.Size && !now.Mode.IsDir() {
+			changes = append(changes, fmt.Sprintf("size %d -> %d", old.Size, now.Size))
+		}
+
+		if old.SHA256 != now.SHA256 {
+			changes = append(changes, "sha256 changed")
+		}
+
+		if old.Link != now.Link {
+			changes = append(changes, fmt.Sprintf("link %s -> %s", old.Link, now.Link))
+		}
+
+		if len(changes) != 0 {
+			report.Flag(path, "modified: "+strings.Join(changes, ", "))
+		}
+	}
+
+	report.Summarize()
+	if len(report.Findings) == 0 || !prompts.Confirm("Should the baseline be updated to the current state?") {
+		return nil
+	}
+
+	baseline.Created = time.Now()
+	baseline.Entries = current
+	return saveIntegrityBaseline(baseline)
+}
@@ -0,0 +1,192 @@
+package script
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethaniccc/simple-osharden/prompts"
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+// limitsFile is the pam_limits config that CoreDumpLimits writes its limits to.
+const limitsFile = "/etc/security/limits.d/osharden.conf"
+
+func init() {
+	RegisterScript(&CoreDumpLimits{})
+}
+
+// CoreDumpLimits is a script that disables core dumps for every user and service, and optionally
+// limits the number of processes and logins each user can have.
+type CoreDumpLimits struct {
+}
+
+func (s *CoreDumpLimits) Name() string {
+	return "corelimits"
+}
+
+func (s *CoreDumpLimits) Description() string {
+	return "Disables core dumps and sets resource limits."
+}
+
+func (s *CoreDumpLimits) RunOnLinux() error {
+	lines := []string{
+		"# Generated by Simple-OSHarden.",
+		"*\tsoft\tcore\t0",
+		"*\thard\tcore\t0",
+	}
+
+	positive := func(s string) bool {
+		n, err := strconv.Atoi(s)
+		return err == nil && n > 0
+	}
+
+	nproc := ""
+	if prompts.Confirm("Should the number of processes per user be limited (to stop fork bombs)?") {
+		nproc = prompts.ValidResponseWithDefaultPrompt("How many processes should each user be allowed? (recommended is 4096)", "4096", positive)
+		lines = append(lines, "*\thard\tnproc\t"+nproc, "root\thard\tnproc\tunlimited")
+	}
+
+	if prompts.Confirm("Should the number of logins per user be limited?") {
+		logins := prompts.ValidResponseWithDefaultPrompt("How many logins should each user be allowed at once? (recommended is 10)", "10", positive)
+		lines = append(lines, "*\thard\tmaxlogins\t"+logins)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(limitsFile), 0755); err != nil {
+		return fmt.Errorf("unable to create %s: %s", filepath.Dir(limitsFile), err.Error())
+	}
+
+	if err := utils.WriteFile(limitsFile, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("unable to write to %s: %s", limitsFile, err.Error())
+	}
+
+	// Services started by systemd do not go through pam_limits, so their limit is set separately.
+	system, err := utils.LoadINIFile("/etc/systemd/system.conf")
+	if err != nil {
+		return err
+	}
+
+	setINIOption(system, "Manager", "DefaultLimitCORE", "0")
+	if err := system.Save(); err != nil {
+		return err
+	}
+
+	coredump, err := utils.LoadINIFile("/etc/systemd/coredump.conf")
+	if err != nil {
+		return err
+	}
+
+	setINIOption(coredump, "Coredump", "Storage", "none")
+	setINIOption(coredump, "Coredump", "ProcessSizeMax", "0")
+	if err := coredump.Save(); err != nil {
+		return err
+	}
+
+	RunCommand("systemctl daemon-reexec")
+
+	report := &Report{}
+	s.verify(report, nproc)
+	report.Summarize()
+
+	return nil
+}
+
+// verify checks that the limits will actually take effect, by looking for anything that overrides them.
+// nproc is the process limit set for users, or an empty string if none was set.
+func (s *CoreDumpLimits) verify(report *Report, nproc string) {
+	// pam_limits has to be in the session stack for limits.d to be applied at login.
+	enabled := false
+	for _, file := range []string{"/etc/pam.d/common-session", "/etc/pam.d/system-auth", "/etc/pam.d/password-auth", "/etc/pam.d/login"} {
+		buffer, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+
+		for _, line := range strings.Split(string(buffer), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) >= 3 && fields[0] == "session" && strings.Contains(line, "pam_limits.so") {
+				enabled = true
+			}
+		}
+	}
+
+	if !enabled {
+		report.Flag("/etc/pam.d", "pam_limits is not enabled, so limits will not be applied at login")
+	}
+
+	// Files in limits.d are read in order after limits.conf, so a later file can override the core limit.
+	files, _ := filepath.Glob("/etc/security/limits.d/*.conf")
+	sort.Strings(files)
+	for _, file := range append([]string{"/etc/security/limits.conf"}, files...) {
+		if file == limitsFile {
+			continue
+		}
+
+		buffer, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+
+		for _, line := range strings.Split(string(buffer), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 4 || strings.HasPrefix(fields[0], "#") || fields[2] != "core" || fields[3] == "0" {
+				continue
+			}
+
+			f := report.Flag(file, fmt.Sprintf("sets a conflicting core limit: %s", strings.Join(fields, " ")))
+			if file > limitsFile {
+				f.Reason += " (this overrides osharden.conf)"
+			}
+		}
+	}
+
+	drop, _ := filepath.Glob("/etc/systemd/coredump.conf.d/*.conf")
+	for _, file := range drop {
+		f, err := utils.LoadINIFile(file)
+		if err != nil {
+			continue
+		}
+
+		if v, ok := f.Get("Coredump", "Storage"); ok && v != "none" {
+			report.Flag(file, fmt.Sprintf("overrides Storage with %s", v))
+		}
+
+		if v, ok := f.Get("Coredump", "ProcessSizeMax"); ok && v != "0" {
+			report.Flag(file, fmt.Sprintf("overrides ProcessSizeMax with %s", v))
+		}
+	}
+
+	// The defaults that systemd gives to services are checked against the limits set for users.
+	out, err := GetCommandOutput("systemctl show -p DefaultLimitCORE,DefaultLimitCORESoft,DefaultLimitNPROC")
+	if err != nil {
+		report.Flag("systemd", fmt.Sprintf("unable to check the default limits of services: %s", err.Error()))
+		return
+	}
+
+	limits := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		if split := strings.SplitN(line, "=", 2); len(split) == 2 {
+			limits[split[0]] = split[1]
+		}
+	}
+
+	for _, key := range []string{"DefaultLimitCORE", "DefaultLimitCORESoft"} {
+		if v, ok := limits[key]; ok && v != "0" {
+			report.Flag("systemd", fmt.Sprintf("%s of services is %s instead of 0", key, v))
+		}
+	}
+
+	if nproc == "" {
+		return
+	}
+
+	// RLIMIT_NPROC counts the processes of a user, so services can still start more processes than
+	// users are allowed to if systemd does not limit them.
+	want, _ := strconv.ParseUint(nproc, 10, 64)
+	if v, err := strconv.ParseUint(limits["DefaultLimitNPROC"], 10, 64); err != nil || v > want {
+		report.Flag("systemd", fmt.Sprintf("DefaultLimitNPROC of services is %s, which is higher than the limit of %s for users", limits["DefaultLimitNPROC"], nproc))
+	}
+}
@@ -0,0 +1,299 @@
+package script
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ethaniccc/simple-osharden/prompts"
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+const (
+	// rsyslogDropIn is the rsyslog config that SystemLogging writes its settings and rules to. It is
+	// included before the rules in rsyslog.conf, so its settings apply to them as well.
+	rsyslogDropIn = "/etc/rsyslog.d/00-osharden.conf"
+	// rsyslogForwardFile is the rsyslog config that forwards every log to a remote server.
+	rsyslogForwardFile = "/etc/rsyslog.d/90-osharden-forward.conf"
+)
+
+// loggedFacilities are the syslog facilities that have to be written to a log file, along with the
+// rule that is added for them if they are not.
+var loggedFacilities = []struct {
+	facility string
+	rule     string
+}{
+	{"auth", "auth,authpriv.*\t\t\t/var/log/auth.log"},
+	{"kern", "kern.*\t\t\t\t/var/log/kern.log"},
+	{"daemon", "daemon.*\t\t\t/var/log/daemon.log"},
+}
+
+func init() {
+	RegisterScript(&SystemLogging{})
+}
+
+// SystemLogging is a script that makes sure logs are kept across reboots, can only be read by
+// administrators, and can be sent to a remote server where an attacker can not remove them.
+type SystemLogging struct {
+}
+
+func (s *SystemLogging) Name() string {
+	return "logging"
+}
+
+func (s *SystemLogging) Description() string {
+	return "Configures journald and rsyslog to keep and protect logs."
+}
+
+func (s *SystemLogging) RunOnLinux() error {
+	if err := s.configureJournald(); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat("/etc/rsyslog.conf"); os.IsNotExist(err) {
+		logger.Info("rsyslog is not installed, logs are only kept by journald")
+		return nil
+	}
+
+	if err := s.configureRsyslog(); err != nil {
+		return err
+	}
+
+	if prompts.Confirm("Should logs be forwarded to a remote syslog server?") {
+		if err := s.configureForwarding(); err != nil {
+			logger.Errorf("unable to configure log forwarding: %s", err.Error())
+		}
+	} else if err := s.removeForwarding(); err != nil {
+		logger.Errorf("unable to remove log forwarding: %s", err.Error())
+	}
+
+	return RunCommand("systemctl restart rsyslog")
+}
+
+// configureJournald makes journald keep compressed logs on disk, up to a size limit.
+func (s *SystemLogging) configureJournald() error {
+	f, err := utils.LoadINIFile("/etc/systemd/journald.conf")
+	if err != nil {
+		return err
+	}
+
+	maxUse := prompts.RawResponseWithDefaultPrompt("How much disk space can the journal use? (recommended is 1G)", "1G")
+	maxFile := prompts.RawResponseWithDefaultPrompt("How large can each journal file be? (recommended is 100M)", "100M")
+
+	setINIOption(f, "Journal", "Storage", "persistent")
+	setINIOption(f, "Journal", "Compress", "yes")
+	setINIOption(f, "Journal", "SystemMaxUse", maxUse)
+	setINIOption(f, "Journal", "SystemMaxFileSize", maxFile)
+	if !f.Changed() {
+		return nil
+	}
+
+	if err := f.Save(); err != nil {
+		return err
+	}
+
+	return RunCommand("systemctl restart systemd-journald")
+}
+
+// configureRsyslog makes rsyslog create log files that other users can not read, and adds rules for
+// any of the loggedFacilities that are not written to a file.
+func (s *SystemLogging) configureRsyslog() error {
+	// A $FileCreateMode in rsyslog.conf would override the one in the drop-in for the rules after it.
+	if _, ok, err := utils.GetDirectiveFromFile("$FileCreateMode", " ", "/etc/rsyslog.conf"); err == nil && ok {
+		if err := utils.SetDirectiveInFile("$FileCreateMode", "0640", " ", "/etc/rsyslog.conf"); err != nil {
+			return err
+		}
+	}
+
+	lines := []string{"# Generated by Simple-OSHarden.", "$FileCreateMode 0640"}
+	selectors := s.selectors()
+	for _, f := range loggedFacilities {
+		logged := false
+		for _, selector := range selectors {
+			if selectorLogs(selector, f.facility) {
+				logged = true
+				break
+			}
+		}
+
+		if logged {
+			logger.Infof("The %s facility is being logged", f.facility)
+			continue
+		}
+
+		logger.Warnf("The %s facility is not being logged, adding a rule for it", f.facility)
+		lines = append(lines, f.rule)
+	}
+
+	if err := utils.WriteFile(rsyslogDropIn, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("unable to write to %s: %s", rsyslogDropIn, err.Error())
+	}
+
+	return nil
+}
+
+// selectors returns the selectors of every rule in the rsyslog config that writes to a file, leaving
+// out the rules written by SystemLogging itself.
+func (s *SystemLogging) selectors() []string {
+	files, _ := filepath.Glob("/etc/rsyslog.d/*.conf")
+	selectors := []string{}
+	for _, file := range append([]string{"/etc/rsyslog.conf"}, files...) {
+		if file == rsyslogDropIn || file == rsyslogForwardFile {
+			continue
+		}
+
+		buffer, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+
+		for _, line := range strings.Split(string(buffer), "\n") {
+			// A rule is a selector followed by an action, such as "kern.* -/var/log/kern.log".
+			fields := strings.Fields(line)
+			if len(fields) < 2 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "$") || !strings.Contains(fields[0], ".") {
+				continue
+			}
+
+			action := strings.TrimPrefix(fields[1], "-")
+			if strings.HasPrefix(action, "/") || strings.HasPrefix(action, "action(") {
+				selectors = append(selectors, fields[0])
+			}
+		}
+	}
+
+	return selectors
+}
+
+// selectorLogs returns true if messages from the facility match the rsyslog selector, such as
+// "*.*;auth,authpriv.none". Later parts of the selector override earlier ones.
+func selectorLogs(selector, facility string) bool {
+	logged := false
+	for _, part := range strings.Split(selector, ";") {
+		split := strings.SplitN(part, ".", 2)
+		if len(split) != 2 {
+			continue
+		}
+
+		for _, f := range strings.Split(split[0], ",") {
+			if f == facility || f == "*" {
+				logged = split[1] != "none"
+			}
+		}
+	}
+
+	return logged
+}
+
+// configureForwarding asks for a remote syslog server, checks that it can be reached and forwards
+// every log to it.
+func (s *SystemLogging) configureForwarding() error {
+	useTLS := prompts.Confirm("Should the logs be encrypted with TLS?")
+	port := "514"
+	if useTLS {
+		port = "6514"
+	}
+
+	host := prompts.RawResponsePrompt("What is the hostname of the syslog server?")
+	if host == "" {
+		return fmt.Errorf("no syslog server was given")
+	}
+
+	port = prompts.RawResponseWithDefaultPrompt(fmt.Sprintf("What port is the syslog server listening on? (default is %s)", port), port)
+
+	action := fmt.Sprintf(`action(type="omfwd" target=%q port=%q protocol="tcp" action.resumeRetryCount="-1" queue.type="linkedList" queue.size="10000"`, host, port)
+	lines := []string{"# Generated by Simple-OSHarden."}
+
+	var config *tls.Config
+	if useTLS {
+		caFile := prompts.RawResponseWithDefaultPrompt("Which CA certificate should the server be verified with? (default is /etc/ssl/certs/ca-certificates.crt)", "/etc/ssl/certs/ca-certificates.crt")
+		buffer, err := os.ReadFile(caFile)
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %s", caFile, err.Error())
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(buffer) {
+			return fmt.Errorf("no certificates were found in %s", caFile)
+		}
+		config = &tls.Config{RootCAs: pool, ServerName: host}
+
+		lines = append(lines, fmt.Sprintf(`global(DefaultNetstreamDriver="gtls" DefaultNetstreamDriverCAFile=%q)`, caFile))
+		action += fmt.Sprintf(` StreamDriver="gtls" StreamDriverMode="1" StreamDriverAuthMode="x509/name" StreamDriverPermittedPeers=%q`, host)
+		logger.Warn("rsyslog needs the rsyslog-gnutls package to forward logs over TLS")
+	}
+	lines = append(lines, "*.* "+action+")")
+
+	if err := testSyslogTarget(net.JoinHostPort(host, port), config, 5*time.Second); err != nil {
+		logger.Warnf("unable to send a test message to the syslog server: %s", err.Error())
+		if !prompts.Confirm("Should log forwarding be configured anyway?") {
+			return nil
+		}
+	} else {
+		logger.Infof("Sent a test message to %s:%s", host, port)
+	}
+
+	if err := utils.WriteFile(rsyslogForwardFile, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("unable to write to %s: %s", rsyslogForwardFile, err.Error())
+	}
+
+	return nil
+}
+
+// removeForwarding removes the forwarding config written by an earlier run, after backing it up, so
+// that logs are no longer sent to the server it was set up with.
+func (s *SystemLogging) removeForwarding() error {
+	if _, err := os.Stat(rsyslogForwardFile); os.IsNotExist(err) {
+		return nil
+	}
+
+	backup, err := utils.BackupFile(rsyslogForwardFile)
+	if err != nil {
+		return fmt.Errorf("unable to back up %s: %s", rsyslogForwardFile, err.Error())
+	}
+
+	if err := os.Remove(rsyslogForwardFile); err != nil {
+		return err
+	}
+
+	logger.Warnf("Removed %s, logs are no longer forwarded (it was backed up to %s)", rsyslogForwardFile, backup)
+	return nil
+}
+
+// testSyslogTarget connects to the syslog server at the given address and sends it a test message.
+// If config is nil the message is sent over plain TCP, otherwise it is sent over TLS.
+func testSyslogTarget(address string, config *tls.Config, timeout time.Duration) error {
+	dialer := &net.Dialer{Timeout: timeout}
+
+	var conn net.Conn
+	var err error
+	if config != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, config)
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+
+	// The message is an RFC 5424 message from the user facility at notice level, framed with a newline
+	// the same way rsyslog frames messages over TCP.
+	msg := fmt.Sprintf("<13>1 %s %s osharden - - - Test message from Simple-OSHarden\n", time.Now().UTC().Format(time.RFC3339), hostname)
+	if err := conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+
+	_, err = conn.Write([]byte(msg))
+	return err
+}
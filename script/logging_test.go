@@ -0,0 +1,132 @@
+package script
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// receiveSyslogMessage accepts one connection on the listener and sends the first line it reads.
+func receiveSyslogMessage(t *testing.T, l net.Listener) <-chan string {
+	messages := make(chan string, 1)
+	go func() {
+		defer close(messages)
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			t.Errorf("unable to read the test message: %s", err.Error())
+			return
+		}
+		messages <- line
+	}()
+
+	return messages
+}
+
+// checkSyslogMessage checks that the message is a newline framed RFC 5424 message.
+func checkSyslogMessage(t *testing.T, messages <-chan string) {
+	msg, ok := <-messages
+	if !ok {
+		t.Fatal("no test message was received")
+	}
+
+	if !strings.HasPrefix(msg, "<13>1 ") || !strings.HasSuffix(msg, " osharden - - - Test message from Simple-OSHarden\n") {
+		t.Fatalf("the test message is not a framed RFC 5424 message: %q", msg)
+	}
+
+	fields := strings.Fields(msg)
+	if _, err := time.Parse(time.RFC3339, fields[1]); err != nil {
+		t.Fatalf("the test message has an invalid timestamp: %s", err.Error())
+	}
+}
+
+func TestSyslogTargetTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	messages := receiveSyslogMessage(t, l)
+	if err := testSyslogTarget(l.Addr().String(), nil, 5*time.Second); err != nil {
+		t.Fatalf("unable to send the test message: %s", err.Error())
+	}
+
+	checkSyslogMessage(t, messages)
+}
+
+func TestSyslogTargetTLS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	messages := receiveSyslogMessage(t, l)
+	if err := testSyslogTarget(l.Addr().String(), &tls.Config{RootCAs: pool, ServerName: "localhost"}, 5*time.Second); err != nil {
+		t.Fatalf("unable to send the test message: %s", err.Error())
+	}
+
+	checkSyslogMessage(t, messages)
+}
+
+func TestSyslogTargetClosedPort(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address := l.Addr().String()
+	l.Close()
+
+	if err := testSyslogTarget(address, nil, 5*time.Second); err == nil {
+		t.Fatal("sending to a closed port did not return an error")
+	}
+}
@@ -0,0 +1,235 @@
+package script
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethaniccc/simple-osharden/prompts"
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+func init() {
+	RegisterScript(&MandatoryAccessControl{})
+}
+
+// MandatoryAccessControl is a script that makes sure AppArmor or SELinux is enforcing its policy,
+// instead of only logging what it would have denied.
+type MandatoryAccessControl struct {
+}
+
+func (s *MandatoryAccessControl) Name() string {
+	return "macenforce"
+}
+
+func (s *MandatoryAccessControl) Description() string {
+	return "Checks AppArmor or SELinux and puts them in enforcing mode."
+}
+
+func (s *MandatoryAccessControl) RunOnLinux() error {
+	report := &Report{}
+	if buffer, err := os.ReadFile("/sys/module/apparmor/parameters/enabled"); err == nil && strings.TrimSpace(string(buffer)) == "Y" {
+		logger.Info("AppArmor is enabled")
+		if err := s.enforceAppArmor(report); err != nil {
+			return err
+		}
+	} else if _, err := os.Stat("/etc/selinux/config"); err == nil {
+		if err := s.enforceSELinux(report); err != nil {
+			return err
+		}
+	} else {
+		return fmt.Errorf("neither AppArmor nor SELinux is available on this machine")
+	}
+
+	report.Summarize()
+	return nil
+}
+
+// enforceAppArmor puts every loaded AppArmor profile that is in complain mode into enforce mode, and
+// lists the processes that are not confined even though there is a profile for them.
+func (s *MandatoryAccessControl) enforceAppArmor(report *Report) error {
+	buffer, err := os.ReadFile("/sys/kernel/security/apparmor/profiles")
+	if err != nil {
+		return fmt.Errorf("unable to read the loaded AppArmor profiles: %s", err.Error())
+	}
+
+	// Each line is the name of the profile followed by its mode, such as "/usr/sbin/cupsd (enforce)".
+	profiles := map[string]string{}
+	for _, line := range strings.Split(string(buffer), "\n") {
+		i := strings.LastIndex(line, " (")
+		if i == -1 {
+			continue
+		}
+
+		profiles[line[:i]] = strings.TrimSuffix(line[i+2:], ")")
+	}
+	logger.Infof("%d AppArmor profile(s) are loaded", len(profiles))
+
+	names := []string{}
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	files := s.appArmorProfileFiles()
+	for _, name := range names {
+		mode := profiles[name]
+		// Child profiles (hats) are enforced along with the profile that contains them.
+		if mode == "enforce" || mode == "kill" || strings.Contains(name, "//") {
+			continue
+		}
+
+		// Profiles in unconfined mode only exist to grant extra permissions (such as user namespaces)
+		// to programs, so enforcing them would break the programs. They are reported, but not enforced.
+		if mode == "unconfined" {
+			report.Flag(name, "AppArmor profile is in unconfined mode, it only grants extra permissions and can not be enforced without breaking the program")
+			continue
+		}
+
+		f := report.Flag(name, fmt.Sprintf("AppArmor profile is in %s mode", mode))
+		file, ok := files[name]
+		if !ok {
+			logger.Warnf("unable to find the file that %s is defined in", name)
+			continue
+		}
+
+		if !prompts.Confirm(fmt.Sprintf("Should the AppArmor profile %s be enforced?", name)) {
+			continue
+		}
+
+		if _, err := exec.LookPath("aa-enforce"); err != nil {
+			return fmt.Errorf("aa-enforce is not installed, install the apparmor-utils package first")
+		}
+
+		if err := RunCommandWithArgs("aa-enforce", file); err != nil {
+			logger.Errorf("unable to enforce %s: %s", name, err.Error())
+			continue
+		}
+
+		f.Fixed = true
+	}
+
+	// A profile only applies to programs started after it was loaded, so anything running since
+	// before then is still unconfined.
+	procs, _ := filepath.Glob("/proc/[0-9]*")
+	for _, proc := range procs {
+		label, err := os.ReadFile(filepath.Join(proc, "attr", "current"))
+		if err != nil || strings.TrimSpace(string(label)) != "unconfined" {
+			continue
+		}
+
+		exe, err := os.Readlink(filepath.Join(proc, "exe"))
+		if err != nil {
+			continue
+		}
+
+		if _, ok := profiles[exe]; ok {
+			report.Flag(exe, fmt.Sprintf("running unconfined as process %s even though it has a profile, restart it to confine it", filepath.Base(proc)))
+		}
+	}
+
+	return nil
+}
+
+// appArmorProfileFiles returns the files in /etc/apparmor.d that each profile is defined in.
+func (s *MandatoryAccessControl) appArmorProfileFiles() map[string]string {
+	files := map[string]string{}
+	entries, err := os.ReadDir("/etc/apparmor.d")
+	if err != nil {
+		return files
+	}
+
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		file := filepath.Join("/etc/apparmor.d", entry.Name())
+		buffer, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+
+		for _, line := range strings.Split(string(buffer), "\n") {
+			// A profile is declared as "profile <name> [attachment] {" or "<path> {".
+			fields := strings.Fields(line)
+			if len(fields) < 2 || !strings.HasSuffix(strings.TrimSpace(line), "{") {
+				continue
+			}
+
+			name := fields[0]
+			if name == "profile" {
+				name = fields[1]
+			} else if !strings.HasPrefix(name, "/") {
+				continue
+			}
+
+			if unquoted, err := strconv.Unquote(name); err == nil {
+				name = unquoted
+			}
+
+			if _, ok := files[name]; !ok {
+				files[name] = file
+			}
+		}
+	}
+
+	return files
+}
+
+// enforceSELinux sets SELinux to enforcing mode, both for the running system and after a reboot.
+func (s *MandatoryAccessControl) enforceSELinux(report *Report) error {
+	mode := "disabled"
+	if out, err := GetCommandOutput("getenforce"); err == nil {
+		mode = strings.ToLower(strings.TrimSpace(out))
+	}
+	logger.Infof("SELinux is currently %s", mode)
+
+	// The stock config explains SELINUX= in comments, so only the uncommented line is read and changed.
+	configured, _, err := utils.GetDirectiveFromFile("SELINUX", "=", "/etc/selinux/config")
+	if err != nil {
+		return err
+	}
+
+	if configured = strings.ToLower(configured); configured != "enforcing" {
+		f := report.Flag("/etc/selinux/config", fmt.Sprintf("SELinux is configured to be %q at boot", configured))
+		if prompts.Confirm("Should SELinux be set to enforcing at boot?") {
+			if err := utils.SetDirectiveInFile("SELINUX", "enforcing", "=", "/etc/selinux/config"); err != nil {
+				return err
+			}
+
+			if value, _, err := utils.GetDirectiveFromFile("SELINUX", "=", "/etc/selinux/config"); err != nil || value != "enforcing" {
+				logger.Errorf("SELINUX is still not set to enforcing in /etc/selinux/config")
+			} else {
+				f.Fixed = true
+			}
+		}
+	}
+
+	switch mode {
+	case "enforcing":
+	case "permissive":
+		f := report.Flag("SELinux", "running in permissive mode")
+		if prompts.Confirm("Should SELinux be switched to enforcing mode now?") {
+			if err := RunCommand("setenforce 1"); err != nil {
+				return fmt.Errorf("unable to switch SELinux to enforcing mode: %s", err.Error())
+			}
+
+			f.Fixed = true
+		}
+	default:
+		// Files created while SELinux was disabled have no labels, and would all be denied without
+		// a relabel.
+		report.Flag("SELinux", "disabled, a reboot is needed to enable it")
+		if err := utils.WriteFile("/.autorelabel", []byte{}, 0644); err != nil {
+			return fmt.Errorf("unable to create /.autorelabel: %s", err.Error())
+		}
+		logger.Warn("The filesystem will be relabeled on the next boot, which can take a while")
+	}
+
+	return nil
+}
@@ -0,0 +1,356 @@
+package script
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethaniccc/simple-osharden/prompts"
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+func init() {
+	RegisterScript(&ProhibitedFiles{})
+}
+
+// prohibitedExtensions maps file extensions to the kind of prohibited file they belong to.
+var prohibitedExtensions = map[string]string{
+	".mp3": "audio", ".flac": "audio", ".wav": "audio", ".ogg": "audio", ".m4a": "audio", ".aac": "audio", ".wma": "audio",
+	".mp4": "video", ".avi": "video", ".mkv": "video", ".mov": "video", ".wmv": "video", ".webm": "video", ".flv": "video",
+	".torrent": "torrent",
+	".kdbx":    "password database",
+}
+
+// prohibitedMagic are the leading bytes of prohibited file types, used to find files with the
+// wrong extension.
+var prohibitedMagic = []struct {
+	offset int
+	magic  []byte
+	kind   string
+}{
+	{0, []byte("ID3"), "audio"},
+	{0, []byte("fLaC"), "audio"},
+	{0, []byte("OggS"), "audio"},
+	{8, []byte("WAVE"), "audio"},
+	{8, []byte("AVI "), "video"},
+	{0, []byte{0x1a, 0x45, 0xdf, 0xa3}, "video"},
+	{0, []byte("d8:announce"), "torrent"},
+	{0, []byte{0x03, 0xd9, 0xa2, 0x9a, 0x67, 0xfb, 0x4b, 0xb5}, "password database"},
+}
+
+// ftypBrands are the major brands of MP4 and QuickTime files, which start with an ftyp box. Photos in
+// HEIC and AVIF use the same container with their own brands, so they are not listed.
+var ftypBrands = map[string]string{
+	"isom": "video", "iso2": "video", "iso4": "video", "iso5": "video", "iso6": "video",
+	"mp41": "video", "mp42": "video", "avc1": "video", "mmp4": "video", "dash": "video",
+	"qt  ": "video", "M4V ": "video", "f4v ": "video", "3gp4": "video", "3gp5": "video", "3gp6": "video", "3g2a": "video",
+	"M4A ": "audio", "M4B ": "audio",
+}
+
+// piratedArchiveNames matches archive names that commonly contain pirated software. The words have to
+// stand on their own, so names such as "crackle.zip" or "patch-1.2.tar.gz" are not matched.
+var piratedArchiveNames = regexp.MustCompile(`(?i)(^|[^a-z])(crack|cracked|keygen|warez|repack|serials?[-_. ]?(key|number)s?)([^a-z].*)?\.(zip|rar|7z|iso|tar|tar\.gz|tgz)$`)
+
+// defaultQuarantine is the directory that quarantined files are moved to by default.
+const defaultQuarantine = "/root/quarantine"
+
+// credentialLine matches lines that look like entries in a credential dump, such as user:password.
+var credentialLine = regexp.MustCompile(`^[^\s:;|]+[:;|][^\s]+$`)
+
+// prohibitedFile is a file found by ProhibitedFiles.
+type prohibitedFile struct {
+	path    string
+	kind    string
+	size    int64
+	finding *Finding
+}
+
+// ProhibitedFiles is a script that scans home directories for media files, torrents, pirated software,
+// password databases and credential dumps. The user is able to delete the files or move them to a
+// quarantine directory.
+type ProhibitedFiles struct {
+}
+
+func (s *ProhibitedFiles) Name() string {
+	return "mediafind"
+}
+
+func (s *ProhibitedFiles) Description() string {
+	return "Finds prohibited media and files in user directories."
+}
+
+func (s *ProhibitedFiles) RunOnLinux() error {
+	users, err := utils.ReadPasswd("/etc/passwd")
+	if err != nil {
+		return err
+	}
+
+	names := map[int]string{}
+	roots := []string{}
+	for _, u := range users {
+		names[u.UID] = u.Name
+	}
+
+	humans, err := humanUsers()
+	if err != nil {
+		return err
+	}
+
+	// root is not a human user, but its home directory is scanned as well.
+	roots = append(roots, "/root")
+	for _, u := range humans {
+		roots = append(roots, u.Home)
+	}
+	roots = append(roots, strings.Fields(prompts.RawResponsePrompt("Any other directories to scan? (separated by spaces)"))...)
+	allowedKeePass := strings.Fields(prompts.RawResponsePrompt("Directories where KeePass databases are allowed? (separated by spaces)"))
+
+	report := &Report{}
+	byUser := map[string][]*prohibitedFile{}
+	seen := map[string]bool{}
+	for _, root := range roots {
+		// Files quarantined by an earlier run are not found again.
+		walkLocalFiles(root, []string{defaultQuarantine}, func(path string, info os.FileInfo) {
+			if seen[path] || !info.Mode().IsRegular() {
+				return
+			}
+			seen[path] = true
+
+			kind := s.classify(path, info)
+			if kind == "" || (kind == "password database" && isExcludedPath(path, allowedKeePass)) {
+				return
+			}
+
+			owner := "unknown"
+			if uid, _, ok := utils.FileOwner(info); ok {
+				owner = names[uid]
+				if owner == "" {
+					owner = strconv.Itoa(uid)
+				}
+			}
+
+			byUser[owner] = append(byUser[owner], &prohibitedFile{
+				path:    path,
+				kind:    kind,
+				size:    info.Size(),
+				finding: report.Flag(path, fmt.Sprintf("%s owned by %s", kind, owner)),
+			})
+		})
+	}
+
+	owners := []string{}
+	for owner := range byUser {
+		owners = append(owners, owner)
+	}
+	sort.Strings(owners)
+
+	quarantine := ""
+	for _, owner := range owners {
+		files := byUser[owner]
+		var total int64
+		for _, f := range files {
+			total += f.size
+		}
+
+		logger.Infof("%s has %d prohibited file(s), totalling %s:", owner, len(files), formatSize(total))
+		for _, f := range files {
+			logger.Infof("  [%s] %s (%s)", f.kind, f.path, formatSize(f.size))
+		}
+
+		action := strings.ToLower(prompts.ValidResponseWithDefaultPrompt("What should be done with these files? (delete/quarantine/review/skip)", "review", oneOf("delete", "quarantine", "review", "skip")))
+		for _, f := range files {
+			choice := action
+			if action == "review" {
+				choice = strings.ToLower(prompts.ValidResponseWithDefaultPrompt(fmt.Sprintf("What should be done with %s? (delete/quarantine/keep)", f.path), "keep", oneOf("delete", "quarantine", "keep")))
+			}
+
+			switch choice {
+			case "delete":
+				if err := os.Remove(f.path); err != nil {
+					logger.Errorf("unable to delete %s: %s", f.path, err.Error())
+					continue
+				}
+			case "quarantine":
+				if quarantine == "" {
+					quarantine = prompts.RawResponseWithDefaultPrompt(fmt.Sprintf("Where should quarantined files be moved to? (default is %s)", defaultQuarantine), defaultQuarantine)
+				}
+
+				if err := s.quarantine(f.path, owner, quarantine); err != nil {
+					logger.Errorf("unable to quarantine %s: %s", f.path, err.Error())
+					continue
+				}
+			default:
+				continue
+			}
+
+			f.finding.Fixed = true
+		}
+	}
+
+	report.Summarize()
+	return nil
+}
+
+// classify returns the kind of prohibited file the path is, or an empty string if it is allowed.
+func (s *ProhibitedFiles) classify(path string, info os.FileInfo) string {
+	name := strings.ToLower(filepath.Base(path))
+	if kind, ok := prohibitedExtensions[filepath.Ext(name)]; ok {
+		return kind
+	}
+
+	if piratedArchiveNames.MatchString(name) {
+		return "pirated software"
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+
+	for _, m := range prohibitedMagic {
+		if len(head) >= m.offset+len(m.magic) && bytes.Equal(head[m.offset:m.offset+len(m.magic)], m.magic) {
+			return m.kind
+		}
+	}
+
+	if len(head) >= 12 && string(head[4:8]) == "ftyp" {
+		if kind, ok := ftypBrands[string(head[8:12])]; ok {
+			return kind
+		}
+	}
+
+	// Only small text files are checked for credentials, since dumps are read in full.
+	if info.Size() > 1<<20 || bytes.IndexByte(head, 0) != -1 {
+		return ""
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return ""
+	}
+
+	buffer, err := io.ReadAll(f)
+	if err != nil {
+		return ""
+	}
+
+	total, matches := 0, 0
+	for _, line := range strings.Split(string(buffer), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		total++
+		if credentialLine.MatchString(line) {
+			matches++
+		}
+	}
+
+	// A file is treated as a credential list if most of its lines look like credentials.
+	if matches >= 5 && matches*2 >= total {
+		return "credential list"
+	}
+
+	return ""
+}
+
+// quarantine moves the file into a per-user directory inside the quarantine directory. Files are never
+// replaced in the quarantine directory, since different paths can be flattened into the same name.
+func (s *ProhibitedFiles) quarantine(path, owner, dir string) error {
+	dest, err := s.reserveQuarantinePath(filepath.Join(dir, owner, strings.ReplaceAll(strings.TrimPrefix(path, "/"), "/", "_")))
+	if err != nil {
+		return err
+	}
+
+	// The reserved file is only a placeholder, so it is safe to rename over it.
+	if err := os.Rename(path, dest); err == nil {
+		return nil
+	}
+
+	// The quarantine directory may be on another filesystem, so fall back to copying the file.
+	src, err := os.Open(path)
+	if err != nil {
+		os.Remove(dest)
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(dest, os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		os.Remove(dest)
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		os.Remove(dest)
+		return err
+	}
+
+	if err := dst.Close(); err != nil {
+		os.Remove(dest)
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// reserveQuarantinePath creates an empty file at the given path, or at the path with a number added
+// if it already exists, and returns the path of the file that was created.
+func (s *ProhibitedFiles) reserveQuarantinePath(path string) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+
+	for i := 0; ; i++ {
+		dest := path
+		if i != 0 {
+			dest = fmt.Sprintf("%s.%d", path, i)
+		}
+
+		f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if os.IsExist(err) {
+			continue
+		} else if err != nil {
+			return "", err
+		}
+
+		return dest, f.Close()
+	}
+}
+
+// oneOf returns a function that returns true if a response is one of the given choices, ignoring case.
+func oneOf(choices ...string) func(string) bool {
+	return func(s string) bool {
+		for _, c := range choices {
+			if strings.EqualFold(s, c) {
+				return true
+			}
+		}
+
+		return false
+	}
+}
+
+// formatSize formats a size in bytes into a human readable string.
+func formatSize(size int64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB"}
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+
+	return fmt.Sprintf("%.1f %s", value, units[unit])
+}
@@ -0,0 +1,149 @@
+package script
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ethaniccc/simple-osharden/prompts"
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+// moduleBlacklistFile is the modprobe config that KernelModules writes its blacklist to.
+const moduleBlacklistFile = "/etc/modprobe.d/osharden.conf"
+
+func init() {
+	RegisterScript(&KernelModules{})
+}
+
+// blacklistCatalog are the kernel modules that KernelModules can disable, along with a description
+// of what they are used for.
+var blacklistCatalog = []struct {
+	name        string
+	description string
+	// fsType is the filesystem type the module provides, if it is a filesystem module.
+	fsType string
+}{
+	{"cramfs", "the cramfs filesystem", "cramfs"},
+	{"freevxfs", "the Veritas filesystem", "vxfs"},
+	{"jffs2", "the JFFS2 flash filesystem", "jffs2"},
+	{"hfs", "the HFS filesystem", "hfs"},
+	{"hfsplus", "the HFS+ filesystem", "hfsplus"},
+	{"squashfs", "the squashfs filesystem (used by snaps)", "squashfs"},
+	{"udf", "the UDF filesystem (used by DVDs)", "udf"},
+	{"usb-storage", "USB storage devices", ""},
+	{"dccp", "the DCCP network protocol", ""},
+	{"sctp", "the SCTP network protocol", ""},
+	{"rds", "the RDS network protocol", ""},
+	{"tipc", "the TIPC network protocol", ""},
+	{"firewire-core", "FireWire devices", ""},
+}
+
+// KernelModules is a script that stops the kernel from loading modules for filesystems and protocols
+// that are rarely used, but increase the attack surface of the kernel.
+type KernelModules struct {
+}
+
+func (s *KernelModules) Name() string {
+	return "kmodblacklist"
+}
+
+func (s *KernelModules) Description() string {
+	return "Blacklists kernel modules for unused filesystems and protocols."
+}
+
+func (s *KernelModules) RunOnLinux() error {
+	loaded := s.loadedModules()
+	mounts, err := utils.ReadMounts("/proc/self/mounts")
+	if err != nil {
+		return err
+	}
+
+	lines := []string{"# Generated by Simple-OSHarden. Modules listed here can not be loaded."}
+	unload := []string{}
+	for _, mod := range blacklistCatalog {
+		key := strings.ReplaceAll(mod.name, "-", "_")
+		users, isLoaded := loaded[key]
+
+		// Skip modules that the running system depends on.
+		if reason := s.dependency(mod.name, mod.fsType, users, mounts); reason != "" {
+			logger.Warnf("Skipping %s: %s", mod.name, reason)
+			continue
+		}
+
+		if !prompts.Confirm(fmt.Sprintf("Should %s (%s) be disabled?", mod.name, mod.description)) {
+			continue
+		}
+
+		lines = append(lines, fmt.Sprintf("install %s /bin/false", mod.name), fmt.Sprintf("blacklist %s", mod.name))
+		if isLoaded {
+			logger.Warnf("%s is currently loaded", mod.name)
+			unload = append(unload, mod.name)
+		}
+	}
+
+	if err := utils.WriteFile(moduleBlacklistFile, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("unable to write to %s: %s", moduleBlacklistFile, err.Error())
+	}
+	logger.Infof("Wrote %d blacklisted module(s) to %s", (len(lines)-1)/2, moduleBlacklistFile)
+
+	if len(unload) == 0 || !prompts.Confirm(fmt.Sprintf("Should the loaded modules (%s) be unloaded now?", strings.Join(unload, ", "))) {
+		return nil
+	}
+
+	for _, mod := range unload {
+		if err := RunCommand("modprobe -r " + mod); err != nil {
+			logger.Warnf("unable to unload %s, it will be unloaded after a reboot: %s", mod, err.Error())
+		}
+	}
+
+	return nil
+}
+
+// loadedModules returns the modules that are currently loaded (as listed by lsmod), along with how many other
+// modules or devices are using them.
+func (s *KernelModules) loadedModules() map[string]int {
+	loaded := map[string]int{}
+	buffer, err := os.ReadFile("/proc/modules")
+	if err != nil {
+		logger.Warnf("unable to read loaded modules: %s", err.Error())
+		return loaded
+	}
+
+	for _, line := range strings.Split(string(buffer), "\n") {
+		// Each line is the name, size and use count of the module, followed by other details.
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+
+		users, _ := strconv.Atoi(fields[2])
+		loaded[fields[0]] = users
+	}
+
+	return loaded
+}
+
+// dependency returns why the running system depends on the module, or an empty string if it does not.
+func (s *KernelModules) dependency(name, fsType string, users int, mounts []utils.MountEntry) string {
+	if fsType != "" {
+		for _, m := range mounts {
+			if m.FSType == fsType {
+				return fmt.Sprintf("%s is mounted as %s", m.MountPoint, fsType)
+			}
+		}
+	}
+
+	if name == "squashfs" {
+		if _, err := os.Stat("/usr/lib/snapd/snapd"); err == nil {
+			return "snaps are installed, which require squashfs"
+		}
+	}
+
+	if users > 0 {
+		return fmt.Sprintf("the module is currently in use by %d other module(s) or device(s)", users)
+	}
+
+	return ""
+}
@@ -0,0 +1,196 @@
+package script
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ethaniccc/simple-osharden/prompts"
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+func init() {
+	RegisterScript(&MountOptions{})
+}
+
+// hardenedMounts are the mount points checked by MountOptions, and the options they must have.
+var hardenedMounts = []struct {
+	mountPoint string
+	options    []string
+}{
+	{"/tmp", []string{"nodev", "nosuid", "noexec"}},
+	{"/var/tmp", []string{"nodev", "nosuid", "noexec"}},
+	{"/dev/shm", []string{"nodev", "nosuid", "noexec"}},
+	{"/home", []string{"nodev"}},
+}
+
+// MountOptions is a script that makes sure temporary directories and home directories are mounted
+// with options that stop devices, setuid programs and (for temporary directories) any programs at
+// all from being used from them.
+type MountOptions struct {
+}
+
+func (s *MountOptions) Name() string {
+	return "mountopts"
+}
+
+func (s *MountOptions) Description() string {
+	return "Hardens the mount options of /tmp, /var/tmp, /dev/shm and /home."
+}
+
+func (s *MountOptions) RunOnLinux() error {
+	fstab, err := utils.LoadFstab("/etc/fstab")
+	if err != nil {
+		return err
+	}
+
+	mounts, err := utils.ReadMounts("/proc/self/mounts")
+	if err != nil {
+		return err
+	}
+
+	// The last entry for a mount point is the one that is visible.
+	live := map[string]utils.MountEntry{}
+	for _, m := range mounts {
+		live[m.MountPoint] = m
+	}
+
+	report := &Report{}
+	// fstabFindings are fixed once the changes to /etc/fstab have been saved.
+	fstabFindings := []*Finding{}
+	for _, target := range hardenedMounts {
+		configured, inFstab := fstab.Find(target.mountPoint)
+		current, isMounted := live[target.mountPoint]
+
+		if inFstab {
+			if missing := missingOptions(configured, target.options); len(missing) != 0 {
+				f := report.Flag(target.mountPoint, fmt.Sprintf("configured in /etc/fstab without %s", strings.Join(missing, ",")))
+				if prompts.Confirm(fmt.Sprintf("Should %s be added to the options of %s in /etc/fstab?", strings.Join(missing, ","), target.mountPoint)) {
+					fstab.SetOptions(target.mountPoint, append(configured.Options, missing...))
+					fstabFindings = append(fstabFindings, f)
+				}
+			}
+		} else if target.mountPoint == "/tmp" {
+			if err := s.configureTmpMount(target.options, report); err != nil {
+				logger.Errorf("unable to configure tmp.mount: %s", err.Error())
+			}
+		} else if target.mountPoint == "/dev/shm" {
+			f := report.Flag(target.mountPoint, "not configured in /etc/fstab")
+			if prompts.Confirm(fmt.Sprintf("Should /dev/shm be added to /etc/fstab with %s?", strings.Join(target.options, ","))) {
+				fstab.Add(utils.MountEntry{Device: "tmpfs", MountPoint: "/dev/shm", FSType: "tmpfs", Options: append([]string{"defaults"}, target.options...)})
+				fstabFindings = append(fstabFindings, f)
+			}
+		} else if !isMounted {
+			logger.Warnf("%s is not a separate filesystem, so its mount options can not be set", target.mountPoint)
+			continue
+		}
+
+		if !isMounted {
+			continue
+		}
+
+		missing := missingOptions(current, target.options)
+		if len(missing) == 0 {
+			logger.Infof("%s is mounted with %s", target.mountPoint, strings.Join(target.options, ","))
+			continue
+		}
+
+		f := report.Flag(target.mountPoint, fmt.Sprintf("currently mounted without %s", strings.Join(missing, ",")))
+		if !prompts.Confirm(fmt.Sprintf("Should %s be remounted with %s now?", target.mountPoint, strings.Join(missing, ","))) {
+			continue
+		}
+
+		if err := RunCommandWithArgs("mount", "-o", "remount,"+strings.Join(missing, ","), target.mountPoint); err != nil {
+			logger.Errorf("unable to remount %s, the options will apply after a reboot: %s", target.mountPoint, err.Error())
+			continue
+		}
+
+		f.Fixed = true
+	}
+
+	if len(fstabFindings) != 0 {
+		if err := fstab.Save(); err != nil {
+			logger.Errorf("unable to save /etc/fstab: %s", err.Error())
+		} else {
+			for _, f := range fstabFindings {
+				f.Fixed = true
+			}
+
+			RunCommand("systemctl daemon-reload")
+		}
+	}
+
+	report.Summarize()
+	return nil
+}
+
+// configureTmpMount uses the systemd tmp.mount unit to mount /tmp as a separate tmpfs with the given
+// options, for when /tmp is not in /etc/fstab.
+func (s *MountOptions) configureTmpMount(opts []string, report *Report) error {
+	override, err := utils.LoadINIFile("/etc/systemd/system/tmp.mount.d/osharden.conf")
+	if err != nil {
+		return err
+	}
+
+	options := strings.Join(append([]string{"mode=1777", "strictatime"}, opts...), ",")
+	if current, ok := override.Get("Mount", "Options"); ok && current == options {
+		return nil
+	}
+
+	finding := report.Flag("/tmp", "not configured in /etc/fstab")
+	if !prompts.Confirm(fmt.Sprintf("Should /tmp be mounted as a tmpfs with %s by tmp.mount at boot?", strings.Join(opts, ","))) {
+		return nil
+	}
+	setINIOption(override, "Mount", "Options", options)
+
+	// Debian ships tmp.mount as an example that has to be copied into place before it can be enabled.
+	unit := "/etc/systemd/system/tmp.mount"
+	found := false
+	for _, path := range []string{unit, "/lib/systemd/system/tmp.mount", "/usr/lib/systemd/system/tmp.mount"} {
+		if _, err := os.Stat(path); err == nil {
+			found = true
+			break
+		}
+	}
+
+	if !found {
+		buffer, err := os.ReadFile("/usr/share/systemd/tmp.mount")
+		if err != nil {
+			return fmt.Errorf("tmp.mount is not available on this machine")
+		}
+
+		if err := utils.WriteFile(unit, buffer, 0644); err != nil {
+			return fmt.Errorf("unable to write to %s: %s", unit, err.Error())
+		}
+	}
+
+	if err := os.MkdirAll("/etc/systemd/system/tmp.mount.d", 0755); err != nil {
+		return fmt.Errorf("unable to create /etc/systemd/system/tmp.mount.d: %s", err.Error())
+	}
+
+	if err := override.Save(); err != nil {
+		return err
+	}
+
+	if err := ExecuteLoggedCommands([]LoggedCommand{
+		{"Reloading systemd", "systemctl daemon-reload", false},
+		{"Enabling tmp.mount", "systemctl enable tmp.mount", false},
+	}); err != nil {
+		return err
+	}
+
+	finding.Fixed = true
+	return nil
+}
+
+// missingOptions returns the options in want that the mount entry does not have.
+func missingOptions(entry utils.MountEntry, want []string) []string {
+	missing := []string{}
+	for _, opt := range want {
+		if !entry.HasOption(opt) {
+			missing = append(missing, opt)
+		}
+	}
+
+	return missing
+}
@@ -0,0 +1,90 @@
+package script
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// distroFamily returns the family of the running distribution, which is either "debian",
+// "rhel", "suse" or an empty string if the distribution is unknown.
+func distroFamily() string {
+	buffer, err := os.ReadFile("/etc/os-release")
+	if err != nil {
+		return ""
+	}
+
+	ids := []string{}
+	for _, line := range strings.Split(string(buffer), "\n") {
+		split := strings.SplitN(line, "=", 2)
+		if len(split) != 2 || (split[0] != "ID" && split[0] != "ID_LIKE") {
+			continue
+		}
+
+		ids = append(ids, strings.Fields(strings.Trim(split[1], "\"'"))...)
+	}
+
+	for _, id := range ids {
+		switch id {
+		case "debian", "ubuntu":
+			return "debian"
+		case "rhel", "fedora", "centos":
+			return "rhel"
+		case "suse", "opensuse":
+			return "suse"
+		}
+	}
+
+	return ""
+}
+
+// packageOwner returns the name of the package that owns the given path, or an empty string
+// if the path is not owned by any package. If more than one package owns the path, their names are
+// separated by commas.
+func packageOwner(path string) string {
+	return strings.Join(packageOwners(path), ", ")
+}
+
+// packageOwners returns the names of the packages that own the given path. Files can be owned by
+// more than one package, such as libraries that are installed for more than one architecture.
+func packageOwners(path string) []string {
+	if _, err := exec.LookPath("dpkg"); err == nil {
+		candidates := []string{path}
+		// Packages on merged /usr systems may still list their files under /bin, /sbin and /lib.
+		if strings.HasPrefix(path, "/usr/") {
+			candidates = append(candidates, strings.TrimPrefix(path, "/usr"))
+		}
+
+		for _, c := range candidates {
+			out, err := GetCommandOutputWithArgs("dpkg", "-S", c)
+			if err != nil {
+				continue
+			}
+
+			for _, line := range strings.Split(out, "\n") {
+				if strings.HasPrefix(line, "diversion by") {
+					continue
+				}
+
+				// Each line is the owners followed by the path, such as "libc6:amd64, libc6:i386: /path".
+				if split := strings.SplitN(line, ": ", 2); len(split) == 2 {
+					return strings.Split(split[0], ", ")
+				}
+			}
+		}
+
+		return nil
+	}
+
+	if _, err := exec.LookPath("rpm"); err == nil {
+		out, err := GetCommandOutputWithArgs("rpm", "-qf", path)
+		if err != nil {
+			return nil
+		}
+
+		// rpm prints one owner per line.
+		return strings.Fields(out)
+	}
+
+	return nil
+}
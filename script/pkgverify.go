@@ -0,0 +1,201 @@
+package script
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/ethaniccc/simple-osharden/prompts"
+)
+
+// binaryDirs are the directories that programs and libraries are installed to.
+var binaryDirs = []string{
+	"/bin/", "/sbin/", "/lib/", "/lib32/", "/lib64/", "/libx32/",
+	"/usr/bin/", "/usr/sbin/", "/usr/lib/", "/usr/lib32/", "/usr/lib64/", "/usr/libx32/", "/usr/libexec/",
+	"/usr/local/bin/", "/usr/local/sbin/", "/usr/local/lib/",
+}
+
+func init() {
+	RegisterScript(&PackageIntegrity{})
+}
+
+// packageChange is an installed file that no longer matches the package it was installed from.
+type packageChange struct {
+	Path string
+	// Attributes are the attributes that changed, as shown by dpkg --verify or rpm -V, such as
+	// "S.5....T.". It is "missing" if the file was removed.
+	Attributes string
+	// Config is true if the file is a config file, which is expected to be changed by administrators.
+	Config bool
+}
+
+// contentChanged returns true if the file was removed, or its contents no longer match the package.
+func (c packageChange) contentChanged() bool {
+	return c.Attributes == "missing" || strings.ContainsAny(c.Attributes, "S5")
+}
+
+// isBinary returns true if the file is a program or a library.
+func (c packageChange) isBinary() bool {
+	for _, dir := range binaryDirs {
+		if strings.HasPrefix(c.Path, dir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PackageIntegrity is a script that checks the files installed by packages against the checksums
+// recorded by the package manager, to find programs and libraries that have been tampered with.
+type PackageIntegrity struct {
+}
+
+func (s *PackageIntegrity) Name() string {
+	return "pkgverify"
+}
+
+func (s *PackageIntegrity) Description() string {
+	return "Verifies installed package files against the package manager."
+}
+
+func (s *PackageIntegrity) RunOnLinux() error {
+	var changes []packageChange
+	var err error
+	if _, lookErr := exec.LookPath("dpkg"); lookErr == nil {
+		changes, err = s.verify("dpkg", "--verify")
+	} else if _, lookErr := exec.LookPath("rpm"); lookErr == nil {
+		logger.Info("Verifying every installed package, this may take a few minutes")
+		changes, err = s.verify("rpm", "-Va")
+	} else {
+		return fmt.Errorf("no supported package manager was found")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	report := &Report{}
+	configs := []packageChange{}
+	findings := map[string][]*Finding{}
+	for _, c := range changes {
+		// Config files are meant to be edited, so changes to them are only listed.
+		if c.Config {
+			configs = append(configs, c)
+			continue
+		}
+
+		reason := ""
+		switch {
+		case c.Attributes == "missing":
+			reason = "removed"
+		case c.contentChanged() && c.isBinary():
+			reason = "program or library has been modified"
+		case c.contentChanged():
+			reason = "has been modified"
+		case c.isBinary() && strings.ContainsAny(c.Attributes, "MUG"):
+			// A changed mode or owner on a program can add setuid bits or let other users replace it.
+			reason = "mode or owner of program or library has changed"
+		default:
+			continue
+		}
+
+		// Files owned by more than one package are grouped by all of their owners, which are reinstalled
+		// together.
+		owner := packageOwner(c.Path)
+		name := owner
+		if name == "" {
+			name = "unknown"
+		}
+
+		findings[owner] = append(findings[owner], report.Flag(c.Path, fmt.Sprintf("%s (%s, package %s)", reason, c.Attributes, name)))
+	}
+
+	if len(configs) != 0 {
+		logger.Infof("%d config file(s) have been changed, which is expected:", len(configs))
+		for _, c := range configs {
+			logger.Infof("  %s (%s)", c.Path, c.Attributes)
+		}
+	}
+
+	pkgs := []string{}
+	for pkg := range findings {
+		if pkg != "" {
+			pkgs = append(pkgs, pkg)
+		}
+	}
+	sort.Strings(pkgs)
+
+	for _, pkg := range pkgs {
+		if !prompts.Confirm(fmt.Sprintf("Should %s be reinstalled to restore its %d changed file(s)?", pkg, len(findings[pkg]))) {
+			continue
+		}
+
+		if err := s.reinstall(strings.Split(pkg, ", ")...); err != nil {
+			logger.Errorf("unable to reinstall %s: %s", pkg, err.Error())
+			continue
+		}
+
+		for _, f := range findings[pkg] {
+			f.Fixed = true
+		}
+	}
+
+	report.Summarize()
+	return nil
+}
+
+// verify runs the package manager's verify command and returns the files that it reported.
+func (s *PackageIntegrity) verify(c string, args ...string) ([]packageChange, error) {
+	// rpm exits with an error when a file does not match, but the output is still valid.
+	out, err := GetCommandOutputWithArgs(c, args...)
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		return nil, fmt.Errorf("unable to verify packages: %s", err.Error())
+	}
+
+	changes := []packageChange{}
+	for _, line := range strings.Split(out, "\n") {
+		// Each line is the changed attributes, an optional file type and the path, such as
+		// "??5?????? c /etc/adduser.conf" or "missing     /usr/bin/tac".
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		// The path is everything after the attributes and the file type, since it can contain spaces.
+		change := packageChange{Attributes: fields[0]}
+		rest := strings.TrimLeft(strings.TrimPrefix(strings.TrimLeft(line, " "), fields[0]), " ")
+		if len(rest) > 2 && rest[0] != '/' && rest[1] == ' ' {
+			switch rest[0] {
+			case 'c':
+				change.Config = true
+			case 'g':
+				// Ghost files are created by the package at runtime, so their contents are never checked.
+				continue
+			}
+			rest = strings.TrimLeft(rest[2:], " ")
+		}
+
+		if !strings.HasPrefix(rest, "/") {
+			continue
+		}
+
+		change.Path = rest
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// reinstall reinstalls the packages, which restores every file that they installed. Changed config
+// files are kept.
+func (s *PackageIntegrity) reinstall(pkgs ...string) error {
+	if distroFamily() == "debian" {
+		cmd := CreateCommand("apt-get", append([]string{"install", "--reinstall", "-y", "-o", "Dpkg::Options::=--force-confold"}, pkgs...)...)
+		cmd.Env = append(os.Environ(), "DEBIAN_FRONTEND=noninteractive")
+		return cmd.Run()
+	}
+
+	return RunCommandWithArgs("dnf", append([]string{"reinstall", "-y"}, pkgs...)...)
+}
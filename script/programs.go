@@ -2,6 +2,8 @@ package script
 
 import (
 	"fmt"
+	"os"
+	"os/exec"
 	"strings"
 
 	"github.com/ethaniccc/simple-osharden/prompts"
@@ -93,8 +95,18 @@ func (s *RemovePrograms) RunOnLinux() error {
 	return ExecuteLoggedCommands(commands)
 }
 
-// UpdatePrograms is a script that updates programs on the system. This is a very simple script
-// that essentially only runs `apt upgrade`.
+// upgradablePackage is an installed package that has a newer version available.
+type upgradablePackage struct {
+	Name      string
+	Current   string
+	Available string
+	// Security is true if the new version comes from a security repository or fixes a security issue.
+	Security bool
+}
+
+// UpdatePrograms is a script that upgrades the packages on the system. The user can choose to
+// upgrade every package, only the packages with security updates, or specific packages, and a
+// report of what changed is shown afterwards.
 type UpdatePrograms struct {
 }
 
@@ -103,12 +115,257 @@ func (s *UpdatePrograms) Name() string {
 }
 
 func (s *UpdatePrograms) Description() string {
-	return "Updates programs on the system."
+	return "Upgrades programs on the system and reports what changed."
 }
 
 func (s *UpdatePrograms) RunOnLinux() error {
-	ResetTerminal()
-	return RunCommand("apt upgrade")
+	var pkgs []upgradablePackage
+	var err error
+	switch distroFamily() {
+	case "debian":
+		pkgs, err = s.aptUpgradable()
+	case "rhel":
+		pkgs, err = s.dnfUpgradable()
+	default:
+		return fmt.Errorf("upgrading packages is not supported on this distro")
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if len(pkgs) == 0 {
+		logger.Info("Every package is up to date")
+		return nil
+	}
+
+	security := 0
+	for _, pkg := range pkgs {
+		mark := ""
+		if pkg.Security {
+			mark = " [security]"
+			security++
+		}
+
+		logger.Infof("%s: %s -> %s%s", pkg.Name, pkg.Current, pkg.Available, mark)
+	}
+	logger.Infof("%d package(s) can be upgraded, %d of them with security updates", len(pkgs), security)
+
+	choice := prompts.RawResponseWithDefaultPrompt("Which packages should be upgraded (all, security, or package names separated by spaces)? (recommended is security)", "security")
+	selected := []upgradablePackage{}
+	switch choice {
+	case "all":
+		selected = pkgs
+	case "security":
+		for _, pkg := range pkgs {
+			if pkg.Security {
+				selected = append(selected, pkg)
+			}
+		}
+	default:
+		names := strings.Fields(choice)
+		for _, name := range names {
+			found := false
+			for _, pkg := range pkgs {
+				if pkg.Name == name {
+					selected = append(selected, pkg)
+					found = true
+				}
+			}
+
+			if !found {
+				logger.Warnf("%s can not be upgraded, skipping", name)
+			}
+		}
+	}
+
+	if len(selected) == 0 {
+		logger.Info("No packages were selected")
+		return nil
+	}
+
+	names := []string{}
+	for _, pkg := range selected {
+		names = append(names, pkg.Name)
+	}
+
+	if err := s.upgrade(names); err != nil {
+		logger.Errorf("unable to upgrade every package: %s", err.Error())
+	}
+
+	s.report(selected)
+	return nil
+}
+
+// aptUpgradable returns the packages that apt can upgrade.
+func (s *UpdatePrograms) aptUpgradable() ([]upgradablePackage, error) {
+	if err := ExecuteLoggedCommands([]LoggedCommand{
+		{"Updating package lists", "apt-get update", false},
+	}); err != nil {
+		return nil, err
+	}
+
+	out, err := GetCommandOutput("apt list --upgradable")
+	if err != nil {
+		return nil, fmt.Errorf("unable to list upgradable packages: %s", err.Error())
+	}
+
+	pkgs := []upgradablePackage{}
+	for _, line := range strings.Split(out, "\n") {
+		// Each line looks like "openssl/bookworm-security 3.0.15-1 amd64 [upgradable from: 3.0.14-1]".
+		fields := strings.Fields(line)
+		if len(fields) < 6 || !strings.Contains(fields[0], "/") {
+			continue
+		}
+
+		split := strings.SplitN(fields[0], "/", 2)
+		pkg := upgradablePackage{
+			Name:      split[0],
+			Available: fields[1],
+			Current:   strings.TrimSuffix(fields[len(fields)-1], "]"),
+		}
+
+		// A package can come from several suites, such as "jammy-updates,jammy-security".
+		for _, suite := range strings.Split(split[1], ",") {
+			if strings.HasSuffix(suite, "-security") {
+				pkg.Security = true
+			}
+		}
+
+		pkgs = append(pkgs, pkg)
+	}
+
+	return pkgs, nil
+}
+
+// dnfUpgradable returns the packages that dnf can upgrade.
+func (s *UpdatePrograms) dnfUpgradable() ([]upgradablePackage, error) {
+	// dnf check-update exits with 100 when there are updates available.
+	out, err := GetCommandOutput("dnf -q check-update")
+	if exit, ok := err.(*exec.ExitError); err != nil && (!ok || exit.ExitCode() != 100) {
+		return nil, fmt.Errorf("unable to list upgradable packages: %s", err.Error())
+	}
+
+	// Each line of dnf updateinfo ends with the full name of the package that fixes the advisory,
+	// such as "openssl-1:3.0.7-27.el9.x86_64".
+	secure := map[string]bool{}
+	if info, err := GetCommandOutput("dnf -q updateinfo list --security"); err == nil {
+		for _, line := range strings.Split(info, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				continue
+			}
+
+			nevra := fields[len(fields)-1]
+			if i := strings.LastIndex(nevra, "."); i != -1 {
+				nevra = nevra[:i]
+			}
+
+			if split := strings.Split(nevra, "-"); len(split) > 2 {
+				secure[strings.Join(split[:len(split)-2], "-")] = true
+			}
+		}
+	}
+
+	pkgs := []upgradablePackage{}
+	seen := map[string]bool{}
+	for _, line := range strings.Split(out, "\n") {
+		// Packages that replace other packages are listed after the upgrades.
+		if strings.HasPrefix(line, "Obsoleting") {
+			break
+		}
+
+		// Each line looks like "openssl.x86_64  1:3.0.7-27.el9  baseos".
+		fields := strings.Fields(line)
+		if len(fields) != 3 || !strings.Contains(fields[0], ".") {
+			continue
+		}
+
+		// Packages installed for more than one architecture are listed once for each of them.
+		name := fields[0][:strings.LastIndex(fields[0], ".")]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		pkgs = append(pkgs, upgradablePackage{
+			Name:      name,
+			Current:   s.installedVersion(name),
+			Available: fields[1],
+			Security:  secure[name],
+		})
+	}
+
+	return pkgs, nil
+}
+
+// upgrade upgrades the given packages without asking any questions. Config files that have been
+// changed on the machine are kept instead of being replaced by the package's version.
+func (s *UpdatePrograms) upgrade(names []string) error {
+	var cmd *exec.Cmd
+	if distroFamily() == "debian" {
+		args := []string{"install", "--only-upgrade", "-y", "-o", "Dpkg::Options::=--force-confdef", "-o", "Dpkg::Options::=--force-confold"}
+		cmd = CreateCommand("apt-get", append(args, names...)...)
+		cmd.Env = append(os.Environ(), "DEBIAN_FRONTEND=noninteractive")
+	} else {
+		// rpm keeps changed config files that are marked as noreplace, and saves the package's
+		// version next to them as .rpmnew.
+		cmd = CreateCommand("dnf", append([]string{"upgrade", "-y"}, names...)...)
+	}
+
+	logger.Infof("Upgrading %d package(s)", len(names))
+	return cmd.Run()
+}
+
+// installedVersion returns the installed version of the package, or an empty string if it is not
+// installed.
+func (s *UpdatePrograms) installedVersion(name string) string {
+	var out string
+	var err error
+	if distroFamily() == "debian" {
+		out, err = GetCommandOutputWithArgs("dpkg-query", "-W", "-f=${Version}", name)
+	} else {
+		out, err = GetCommandOutputWithArgs("rpm", "-q", "--qf", "%{EPOCH}:%{VERSION}-%{RELEASE}", name)
+		// Packages without an epoch are shown as "(none):".
+		out = strings.TrimPrefix(out, "(none):")
+	}
+
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(out)
+}
+
+// report logs the version of each package before and after the upgrade, and whether the machine
+// has to be rebooted for the upgrades to take effect.
+func (s *UpdatePrograms) report(pkgs []upgradablePackage) {
+	report := &Report{}
+	for _, pkg := range pkgs {
+		after := s.installedVersion(pkg.Name)
+		if after == pkg.Current {
+			report.Flag(pkg.Name, fmt.Sprintf("was not upgraded, still at %s", pkg.Current))
+			continue
+		}
+
+		logger.Infof("%s: upgraded from %s to %s", pkg.Name, pkg.Current, after)
+	}
+
+	if _, err := os.Stat("/var/run/reboot-required"); err == nil {
+		reason := "a reboot is required"
+		if buffer, err := os.ReadFile("/var/run/reboot-required.pkgs"); err == nil {
+			reason += " by " + strings.Join(strings.Fields(string(buffer)), ", ")
+		}
+
+		report.Flag("/var/run/reboot-required", reason)
+	} else if _, err := exec.LookPath("needs-restarting"); err == nil {
+		// needs-restarting -r exits with 1 when a reboot is required.
+		if err := exec.Command("needs-restarting", "-r").Run(); err != nil {
+			report.Flag("needs-restarting", "a reboot is required")
+		}
+	}
+
+	report.Summarize()
 }
 
 // appUninstallLinux will uninstall the program on linux and remove any traces of it.
@@ -0,0 +1,334 @@
+package script
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ethaniccc/simple-osharden/prompts"
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+// officialMirrorDomains are the domains of the official package mirrors of each distro. Packages from
+// these mirrors are signed, so they can safely be downloaded over plain HTTP.
+var officialMirrorDomains = []string{
+	"debian.org", "ubuntu.com", "fedoraproject.org", "redhat.com", "centos.org",
+	"rockylinux.org", "almalinux.org", "opensuse.org", "suse.com",
+}
+
+func init() {
+	RegisterScript(&RepositoryAudit{})
+}
+
+// repoSource is a package repository configured on the machine.
+type repoSource struct {
+	// File is the file that the repository is configured in.
+	File string
+	// Line is the index of the line that configures the repository in a one-line apt file, or the
+	// first line of its stanza in a deb822 file. It is -1 for yum repositories.
+	Line int
+	// Section is the section that configures the repository in a yum repo file.
+	Section string
+	URIs    []string
+	// Insecure is true if packages from the repository are installed without checking their signature.
+	Insecure bool
+}
+
+// String returns a short description of where the repository is configured.
+func (r repoSource) String() string {
+	if r.Section != "" {
+		return fmt.Sprintf("%s [%s]", r.File, r.Section)
+	}
+
+	return fmt.Sprintf("%s:%d", r.File, r.Line+1)
+}
+
+// RepositoryAudit is a script that looks for package repositories that could be used to install
+// malicious packages, because their packages are not signed or could be tampered with in transit.
+type RepositoryAudit struct {
+}
+
+func (s *RepositoryAudit) Name() string {
+	return "repoaudit"
+}
+
+func (s *RepositoryAudit) Description() string {
+	return "Audits APT and YUM repositories for insecure sources."
+}
+
+func (s *RepositoryAudit) RunOnLinux() error {
+	sources := []repoSource{}
+	if _, err := os.Stat("/etc/apt"); err == nil {
+		files, _ := filepath.Glob("/etc/apt/sources.list.d/*.list")
+		for _, file := range append([]string{"/etc/apt/sources.list"}, files...) {
+			sources = append(sources, s.parseAptList(file)...)
+		}
+
+		files, _ = filepath.Glob("/etc/apt/sources.list.d/*.sources")
+		for _, file := range files {
+			sources = append(sources, s.parseDeb822(file)...)
+		}
+	}
+
+	files, _ := filepath.Glob("/etc/yum.repos.d/*.repo")
+	for _, file := range files {
+		sources = append(sources, s.parseYumRepo(file)...)
+	}
+	logger.Infof("Found %d enabled repositories", len(sources))
+
+	report := &Report{}
+	changed := []string{}
+	disabled := map[string][]repoSource{}
+	findings := map[string][]*Finding{}
+	for _, source := range sources {
+		reasons := []string{}
+		if source.Insecure {
+			reasons = append(reasons, "signatures are not checked")
+		}
+
+		for _, uri := range source.URIs {
+			if u, err := url.Parse(uri); err == nil && u.Scheme == "http" && !isOfficialMirror(u.Hostname()) {
+				reasons = append(reasons, fmt.Sprintf("%s is not an official mirror and does not use HTTPS", u.Host))
+			}
+		}
+
+		if len(reasons) == 0 {
+			continue
+		}
+
+		f := report.Flag(source.String(), strings.Join(reasons, ", "))
+		if !prompts.Confirm(fmt.Sprintf("Should the repository at %s be disabled?", source.String())) {
+			continue
+		}
+
+		if _, ok := disabled[source.File]; !ok {
+			changed = append(changed, source.File)
+		}
+		disabled[source.File] = append(disabled[source.File], source)
+		findings[source.File] = append(findings[source.File], f)
+	}
+
+	// Each file is rewritten once, so that the lines of the repositories in it do not move while they
+	// are being disabled.
+	for _, file := range changed {
+		if err := s.disable(file, disabled[file]); err != nil {
+			logger.Errorf("unable to disable the repositories in %s: %s", file, err.Error())
+			continue
+		}
+
+		for _, f := range findings[file] {
+			f.Fixed = true
+		}
+	}
+
+	// Keys in trusted.gpg are trusted for every repository, instead of only the one that uses them.
+	if info, err := os.Stat("/etc/apt/trusted.gpg"); err == nil && info.Size() != 0 {
+		report.Flag("/etc/apt/trusted.gpg", "contains keys that are trusted for every repository, move them to /etc/apt/keyrings and use signed-by instead")
+	}
+
+	report.Summarize()
+	return nil
+}
+
+// parseAptList returns the enabled repositories in a one-line apt sources file, such as
+// "deb [signed-by=/etc/apt/keyrings/example.gpg] https://example.com/debian stable main".
+func (s *RepositoryAudit) parseAptList(file string) []repoSource {
+	buffer, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+
+	sources := []repoSource{}
+	for i, line := range strings.Split(string(buffer), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || (fields[0] != "deb" && fields[0] != "deb-src") {
+			continue
+		}
+
+		source := repoSource{File: file, Line: i}
+		rest := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), fields[0]))
+		if strings.HasPrefix(rest, "[") {
+			// Options can be written with spaces inside the brackets, such as "[ arch=amd64 ]".
+			end := strings.Index(rest, "]")
+			if end == -1 {
+				continue
+			}
+
+			for _, opt := range strings.Fields(rest[1:end]) {
+				if opt == "trusted=yes" {
+					source.Insecure = true
+				}
+			}
+			rest = rest[end+1:]
+		}
+
+		if uri := strings.Fields(rest); len(uri) != 0 {
+			source.URIs = []string{uri[0]}
+		}
+
+		sources = append(sources, source)
+	}
+
+	return sources
+}
+
+// parseDeb822 returns the enabled repositories in a deb822 apt sources file, where each repository is
+// a stanza of "Key: value" lines.
+func (s *RepositoryAudit) parseDeb822(file string) []repoSource {
+	buffer, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+
+	sources := []repoSource{}
+	var current *repoSource
+	enabled := true
+	finish := func() {
+		if current != nil && enabled && len(current.URIs) != 0 {
+			sources = append(sources, *current)
+		}
+
+		current, enabled = nil, true
+	}
+
+	for i, line := range strings.Split(string(buffer), "\n") {
+		if strings.TrimSpace(line) == "" {
+			finish()
+			continue
+		}
+
+		// Lines starting with whitespace continue the value of the previous key, such as an
+		// embedded key in Signed-By.
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+			continue
+		}
+
+		if current == nil {
+			current = &repoSource{File: file, Line: i}
+		}
+
+		split := strings.SplitN(line, ":", 2)
+		if len(split) != 2 {
+			continue
+		}
+
+		value := strings.TrimSpace(split[1])
+		switch strings.ToLower(split[0]) {
+		case "uris":
+			current.URIs = strings.Fields(value)
+		case "trusted":
+			current.Insecure = strings.EqualFold(value, "yes")
+		case "enabled":
+			enabled = !strings.EqualFold(value, "no")
+		}
+	}
+	finish()
+
+	return sources
+}
+
+// parseYumRepo returns the enabled repositories in a yum repo file.
+func (s *RepositoryAudit) parseYumRepo(file string) []repoSource {
+	f, err := utils.LoadINIFile(file)
+	if err != nil {
+		return nil
+	}
+
+	sources := []repoSource{}
+	for _, section := range f.Sections() {
+		if enabled, ok := f.Get(section, "enabled"); ok && enabled == "0" {
+			continue
+		}
+
+		source := repoSource{File: file, Line: -1, Section: section}
+		for _, key := range []string{"baseurl", "mirrorlist", "metalink"} {
+			if v, ok := f.Get(section, key); ok {
+				source.URIs = append(source.URIs, strings.FieldsFunc(v, func(r rune) bool { return r == ',' || r == ' ' })...)
+			}
+		}
+
+		if v, ok := f.Get(section, "gpgcheck"); ok && (v == "0" || v == "false" || v == "no") {
+			source.Insecure = true
+		}
+
+		sources = append(sources, source)
+	}
+
+	return sources
+}
+
+// disable disables the given repositories, which are all configured in the file, after backing the
+// file up.
+func (s *RepositoryAudit) disable(file string, sources []repoSource) error {
+	backup, err := utils.BackupFile(file)
+	if err != nil {
+		return fmt.Errorf("unable to back up %s: %s", file, err.Error())
+	}
+	logger.Infof("Backed up %s to %s", file, backup)
+
+	if sources[0].Section != "" {
+		f, err := utils.LoadINIFile(file)
+		if err != nil {
+			return err
+		}
+
+		for _, source := range sources {
+			f.Set(source.Section, "enabled", "0")
+		}
+		return f.Save()
+	}
+
+	buffer, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %s", file, err.Error())
+	}
+
+	// The repositories are disabled from the end of the file, so that a line added to one stanza does
+	// not move the stanzas before it.
+	sort.Slice(sources, func(i, j int) bool {
+		return sources[i].Line > sources[j].Line
+	})
+
+	lines := strings.Split(string(buffer), "\n")
+	for _, source := range sources {
+		if !strings.HasSuffix(file, ".sources") {
+			lines[source.Line] = "# Disabled by Simple-OSHarden: " + lines[source.Line]
+			continue
+		}
+
+		// Replace the Enabled field of the stanza if it has one, otherwise add one.
+		end := source.Line
+		for end < len(lines) && strings.TrimSpace(lines[end]) != "" {
+			if strings.HasPrefix(strings.ToLower(lines[end]), "enabled:") {
+				lines[end] = "Enabled: no"
+				end = -1
+				break
+			}
+			end++
+		}
+
+		if end != -1 {
+			lines = append(lines[:end], append([]string{"Enabled: no"}, lines[end:]...)...)
+		}
+	}
+
+	if err := utils.WriteFile(file, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		return fmt.Errorf("unable to write to %s: %s", file, err.Error())
+	}
+
+	return nil
+}
+
+// isOfficialMirror returns true if the host belongs to one of the officialMirrorDomains.
+func isOfficialMirror(host string) bool {
+	for _, domain := range officialMirrorDomains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+
+	return false
+}
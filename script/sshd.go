@@ -0,0 +1,120 @@
+package script
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+const (
+	// sshdConfig is the main config file of the SSH server.
+	sshdConfig = "/etc/ssh/sshd_config"
+	// sshdDropIn is the drop-in that options set by Simple-OSHarden are written to. sshd uses the first
+	// value it reads for an option, and this file is read before the other drop-ins.
+	sshdDropIn = "/etc/ssh/sshd_config.d/00-osharden.conf"
+)
+
+// sshdInstalled returns true if the SSH server is installed.
+func sshdInstalled() bool {
+	_, err := os.Stat(sshdConfig)
+	return err == nil
+}
+
+// setSSHDOption sets an option of the SSH server, checks that sshd uses the new value and reloads
+// it. Every file that already sets the option is changed, so that no file disagrees with the new value.
+func setSSHDOption(key, value string) error {
+	dropIns, _ := filepath.Glob("/etc/ssh/sshd_config.d/*.conf")
+	for _, file := range append([]string{sshdConfig}, dropIns...) {
+		if _, ok, _ := utils.GetDirectiveFromFile(key, " ", file); !ok {
+			continue
+		}
+
+		if err := utils.SetDirectiveInFile(key, value, " ", file); err != nil {
+			return err
+		}
+	}
+
+	// The drop-ins are included near the top of sshd_config, so an option set there takes effect even
+	// if sshd_config does not set it, or only sets it in a Match block.
+	file := sshdConfig
+	if sshdIncludesDropIns() {
+		file = sshdDropIn
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			if err := utils.WriteFile(file, []byte("# Generated by Simple-OSHarden.\n"), 0644); err != nil {
+				return fmt.Errorf("unable to write to %s: %s", file, err.Error())
+			}
+		}
+	}
+
+	if err := utils.SetDirectiveInFile(key, value, " ", file); err != nil {
+		return err
+	}
+
+	effective, err := sshdEffectiveOption(key)
+	if err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(effective, value) {
+		return fmt.Errorf("sshd still uses %s %s, check the files in /etc/ssh for a conflicting value", key, effective)
+	}
+
+	return reloadSSHD()
+}
+
+// sshdIncludesDropIns returns true if sshd_config includes the files in /etc/ssh/sshd_config.d.
+func sshdIncludesDropIns() bool {
+	buffer, err := os.ReadFile(sshdConfig)
+	if err != nil {
+		return false
+	}
+
+	for _, line := range strings.Split(string(buffer), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && strings.EqualFold(fields[0], "Include") && strings.Contains(line, "sshd_config.d") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sshdEffectiveOption returns the value that sshd uses for the option, after reading every config
+// file, as shown by sshd -T.
+func sshdEffectiveOption(key string) (string, error) {
+	out, err := GetCommandOutput("sshd -T")
+	if err != nil {
+		return "", fmt.Errorf("unable to check the sshd config with sshd -T: %s", err.Error())
+	}
+
+	// Each line is an option in lowercase followed by its value, such as "permitrootlogin no".
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && strings.EqualFold(fields[0], key) {
+			return strings.Join(fields[1:], " "), nil
+		}
+	}
+
+	return "", fmt.Errorf("sshd -T did not show %s", key)
+}
+
+// reloadSSHD reloads the SSH server, whose unit is called ssh on Debian and sshd on other distros.
+// Nothing is reloaded if the server is not running, since it reads its config when it starts.
+func reloadSSHD() error {
+	for _, unit := range []string{"ssh", "sshd"} {
+		if _, err := GetCommandOutput("systemctl cat " + unit + ".service"); err != nil {
+			continue
+		}
+
+		if err := RunCommand("systemctl try-reload-or-restart " + unit); err != nil {
+			return fmt.Errorf("unable to reload %s: %s", unit, err.Error())
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("unable to find the systemd unit of the SSH server")
+}
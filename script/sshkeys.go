@@ -0,0 +1,340 @@
+package script
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ethaniccc/simple-osharden/prompts"
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+// minRSAKeyBits is the smallest RSA key size that is not considered weak.
+const minRSAKeyBits = 2048
+
+func init() {
+	RegisterScript(&SSHKeyAudit{})
+}
+
+// SSHKeyAudit is a script that goes through the authorized_keys files of every account on the
+// machine, and flags any keys that are weak or not in the allowlist. The user is then able to
+// remove the flagged keys.
+type SSHKeyAudit struct {
+}
+
+func (s *SSHKeyAudit) Name() string {
+	return "sshkeys"
+}
+
+func (s *SSHKeyAudit) Description() string {
+	return "Audits the authorized SSH keys of every user."
+}
+
+func (s *SSHKeyAudit) RunOnLinux() error {
+	users, err := utils.ReadPasswd("/etc/passwd")
+	if err != nil {
+		return err
+	}
+
+	// Load the allowlist of key fingerprints, if the user has one.
+	allowed := map[string]bool{}
+	if file := prompts.RawResponsePrompt("Path to a file of allowed key fingerprints (leave empty to skip)"); file != "" {
+		buffer, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("unable to read %s: %s", file, err.Error())
+		}
+
+		for _, line := range strings.Split(string(buffer), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			allowed[line] = true
+		}
+	}
+
+	patterns := authorizedKeysPatterns("/etc/ssh/sshd_config")
+	report := &Report{}
+	seen := map[string]bool{}
+	for _, user := range users {
+		if user.Home == "" {
+			continue
+		}
+
+		for _, pattern := range patterns {
+			file := expandAuthorizedKeysPattern(pattern, user)
+			if seen[file] {
+				continue
+			}
+			seen[file] = true
+
+			if err := s.auditFile(file, user.Name, allowed, report); err != nil {
+				logger.Errorf("unable to audit %s: %s", file, err.Error())
+			}
+		}
+	}
+
+	report.Summarize()
+	return nil
+}
+
+// auditFile checks every key in the given authorized_keys file and asks the user if flagged
+// keys should be removed.
+func (s *SSHKeyAudit) auditFile(file, user string, allowed map[string]bool, report *Report) error {
+	buffer, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(buffer), "\n")
+	removed := false
+	for i, line := range lines {
+		key, err := parseAuthorizedKey(line)
+		if err != nil {
+			report.Flag(file, fmt.Sprintf("line %d could not be parsed: %s", i+1, err.Error()))
+			continue
+		} else if key == nil {
+			continue
+		}
+
+		logger.Infof("[%s] %s %d bits %s (%s)", user, key.Type, key.Bits, key.Fingerprint, key.Comment)
+		if len(key.Options) != 0 {
+			logger.Infof("  options: %s", strings.Join(key.Options, ","))
+		}
+
+		reasons := []string{}
+		if strings.HasPrefix(key.Type, "ssh-dss") {
+			reasons = append(reasons, "DSA keys are deprecated")
+		} else if strings.Contains(key.Type, "rsa") && key.Bits < minRSAKeyBits {
+			reasons = append(reasons, fmt.Sprintf("RSA key is smaller than %d bits", minRSAKeyBits))
+		}
+
+		if len(allowed) != 0 && !allowed[key.Fingerprint] {
+			reasons = append(reasons, "fingerprint is not in the allowlist")
+		}
+
+		if len(reasons) == 0 {
+			continue
+		}
+
+		f := report.Flag(file, fmt.Sprintf("%s key %s (%s): %s", user, key.Fingerprint, key.Comment, strings.Join(reasons, ", ")))
+		if !prompts.Confirm(fmt.Sprintf("Should the key %s be removed from %s?", key.Fingerprint, file)) {
+			continue
+		}
+
+		lines[i] = ""
+		removed = true
+		f.Fixed = true
+	}
+
+	if !removed {
+		return nil
+	}
+
+	// Drop the blank lines left behind by the removed keys.
+	kept := []string{}
+	for _, line := range lines {
+		if line != "" {
+			kept = append(kept, line)
+		}
+	}
+
+	return utils.WriteFile(file, []byte(strings.Join(kept, "\n")+"\n"), 0600)
+}
+
+// authorizedKeysPatterns returns the AuthorizedKeysFile patterns set in the given sshd_config,
+// or the OpenSSH defaults if none are set.
+func authorizedKeysPatterns(file string) []string {
+	defaults := []string{".ssh/authorized_keys", ".ssh/authorized_keys2"}
+
+	buffer, err := os.ReadFile(file)
+	if err != nil {
+		return defaults
+	}
+
+	for _, line := range strings.Split(string(buffer), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "AuthorizedKeysFile") {
+			continue
+		}
+
+		if len(fields) == 2 && fields[1] == "none" {
+			return []string{}
+		}
+
+		return fields[1:]
+	}
+
+	return defaults
+}
+
+// expandAuthorizedKeysPattern expands the tokens in an AuthorizedKeysFile pattern for the given user.
+func expandAuthorizedKeysPattern(pattern string, user utils.PasswdEntry) string {
+	path := strings.NewReplacer(
+		"%%", "%",
+		"%h", user.Home,
+		"%u", user.Name,
+		"%U", strconv.Itoa(user.UID),
+	).Replace(pattern)
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(user.Home, path)
+	}
+
+	return path
+}
+
+// authorizedKey is a single public key parsed from an authorized_keys file.
+type authorizedKey struct {
+	Options     []string
+	Type        string
+	Bits        int
+	Fingerprint string
+	Comment     string
+}
+
+// parseAuthorizedKey parses a line from an authorized_keys file. A nil key is returned
+// if the line is empty or a comment.
+func parseAuthorizedKey(line string) (*authorizedKey, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+
+	key := &authorizedKey{}
+
+	// If the line does not start with a key type, it starts with a list of options. Options
+	// may contain quoted values with spaces, so the end of the list has to be found manually.
+	if !isSSHKeyType(strings.Fields(line)[0]) {
+		quoted := false
+		end := len(line)
+		for i, c := range line {
+			if c == '"' {
+				quoted = !quoted
+			} else if (c == ' ' || c == '\t') && !quoted {
+				end = i
+				break
+			}
+		}
+
+		key.Options = splitKeyOptions(line[:end])
+		line = strings.TrimSpace(line[end:])
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("missing key data")
+	}
+
+	key.Type = fields[0]
+	key.Comment = strings.Join(fields[2:], " ")
+
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid key data: %s", err.Error())
+	}
+
+	sum := sha256.Sum256(blob)
+	key.Fingerprint = "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:])
+
+	key.Bits, err = sshKeyBits(key.Type, blob)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// isSSHKeyType returns true if the given string looks like an SSH public key type.
+func isSSHKeyType(s string) bool {
+	return strings.HasPrefix(s, "ssh-") || strings.HasPrefix(s, "ecdsa-") || strings.HasPrefix(s, "sk-")
+}
+
+// splitKeyOptions splits a comma separated option list, ignoring commas in quoted values.
+func splitKeyOptions(s string) []string {
+	opts := []string{}
+	quoted := false
+	start := 0
+	for i, c := range s {
+		if c == '"' {
+			quoted = !quoted
+		} else if c == ',' && !quoted {
+			opts = append(opts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(opts, s[start:])
+}
+
+// sshKeyBits returns the size of the key in the given SSH wire format blob.
+func sshKeyBits(keyType string, blob []byte) (int, error) {
+	// readString reads the next length-prefixed string from the blob.
+	readString := func() ([]byte, error) {
+		if len(blob) < 4 {
+			return nil, fmt.Errorf("truncated key data")
+		}
+
+		n := binary.BigEndian.Uint32(blob)
+		if uint32(len(blob)-4) < n {
+			return nil, fmt.Errorf("truncated key data")
+		}
+
+		s := blob[4 : 4+n]
+		blob = blob[4+n:]
+		return s, nil
+	}
+
+	name, err := readString()
+	if err != nil {
+		return 0, err
+	}
+
+	// Certificates have a random nonce before the fields of the key they certify.
+	if strings.HasSuffix(string(name), "-cert-v01@openssh.com") {
+		if _, err := readString(); err != nil {
+			return 0, err
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(keyType, "ssh-rsa"), strings.HasPrefix(keyType, "rsa-sha2"):
+		// The exponent comes before the modulus.
+		if _, err := readString(); err != nil {
+			return 0, err
+		}
+
+		n, err := readString()
+		if err != nil {
+			return 0, err
+		}
+
+		return new(big.Int).SetBytes(n).BitLen(), nil
+	case strings.HasPrefix(keyType, "ssh-dss"):
+		p, err := readString()
+		if err != nil {
+			return 0, err
+		}
+
+		return new(big.Int).SetBytes(p).BitLen(), nil
+	case strings.Contains(keyType, "nistp256"):
+		return 256, nil
+	case strings.Contains(keyType, "nistp384"):
+		return 384, nil
+	case strings.Contains(keyType, "nistp521"):
+		return 521, nil
+	case strings.Contains(keyType, "ed25519"):
+		return 256, nil
+	}
+
+	return 0, fmt.Errorf("unknown key type %s", string(name))
+}
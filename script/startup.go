@@ -0,0 +1,301 @@
+package script
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethaniccc/simple-osharden/prompts"
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+// recentlyModified is how recently a startup item has to have been modified to be flagged.
+const recentlyModified = time.Hour * 24 * 30
+
+func init() {
+	RegisterScript(&StartupAudit{})
+}
+
+// startupKind is the kind of autostart location a startupItem was found in, which decides how
+// the item is disabled.
+type startupKind int
+
+const (
+	// startupUnit is a systemd unit that is enabled.
+	startupUnit startupKind = iota
+	// startupExecutable is a script that only runs if it is executable, such as /etc/rc.local.
+	startupExecutable
+	// startupInitScript is a SysV init script.
+	startupInitScript
+	// startupDesktop is an XDG autostart entry.
+	startupDesktop
+	// startupDropIn is a file in a directory where every file is loaded, such as /etc/profile.d.
+	startupDropIn
+	// startupShellFile is a shell startup file that cannot be disabled as a whole, such as /etc/profile.
+	startupShellFile
+)
+
+// startupItem is a single autostart entry found by StartupAudit.
+type startupItem struct {
+	kind    startupKind
+	path    string
+	unit    string
+	pkg     string
+	user    bool
+	reasons []string
+	finding *Finding
+}
+
+// StartupAudit is a script that lists everything that runs automatically when the machine boots
+// or a user logs in. Entries that are not owned by a package, were modified recently or contain
+// suspicious commands are flagged, and the user is able to disable them.
+type StartupAudit struct {
+}
+
+func (s *StartupAudit) Name() string {
+	return "startupaudit"
+}
+
+func (s *StartupAudit) Description() string {
+	return "Audits startup and login scripts for persistence."
+}
+
+func (s *StartupAudit) RunOnLinux() error {
+	items := []*startupItem{}
+	add := func(kind startupKind, user bool, paths ...string) {
+		for _, path := range paths {
+			if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+				items = append(items, &startupItem{kind: kind, path: path, user: user})
+			}
+		}
+	}
+
+	add(startupExecutable, false, "/etc/rc.local")
+	add(startupShellFile, false, "/etc/profile", "/etc/bash.bashrc", "/etc/ld.so.preload")
+	initScripts, _ := filepath.Glob("/etc/init.d/*")
+	add(startupInitScript, false, initScripts...)
+
+	for _, pattern := range []string{"/etc/profile.d/*", "/etc/xdg/autostart/*.desktop", "/etc/udev/rules.d/*.rules", "/lib/udev/rules.d/*.rules"} {
+		matches, _ := filepath.Glob(pattern)
+		kind := startupDropIn
+		if strings.HasSuffix(pattern, ".desktop") {
+			kind = startupDesktop
+		}
+
+		add(kind, false, matches...)
+	}
+
+	humans, err := humanUsers()
+	if err != nil {
+		return err
+	}
+
+	for _, u := range humans {
+		add(startupShellFile, true, filepath.Join(u.Home, ".bashrc"), filepath.Join(u.Home, ".profile"), filepath.Join(u.Home, ".bash_profile"))
+		matches, _ := filepath.Glob(filepath.Join(u.Home, ".config/autostart/*.desktop"))
+		add(startupDesktop, true, matches...)
+	}
+
+	items = append(items, s.enabledUnits()...)
+
+	logger.Info("Checking startup items, this may take a while...")
+	report := &Report{}
+	flagged, other := []*startupItem{}, []*startupItem{}
+	for _, item := range items {
+		s.check(item)
+
+		origin := item.pkg
+		if origin == "" {
+			origin = "no package"
+		}
+
+		name := item.path
+		if item.unit != "" {
+			name = fmt.Sprintf("%s (%s)", item.unit, item.path)
+		}
+
+		logger.Infof("%s [%s]", name, origin)
+		if len(item.reasons) == 0 {
+			other = append(other, item)
+			continue
+		}
+
+		item.finding = report.Flag(name, strings.Join(item.reasons, ", "))
+		flagged = append(flagged, item)
+	}
+
+	for _, item := range flagged {
+		s.review(item)
+	}
+
+	if len(other) != 0 && prompts.Confirm(fmt.Sprintf("Would you like to review the other %d startup item(s)?", len(other))) {
+		for _, item := range other {
+			s.review(item)
+		}
+	}
+
+	report.Summarize()
+	return nil
+}
+
+// enabledUnits returns the systemd units that are enabled in /etc/systemd/system, along with any
+// units that were created there directly.
+func (s *StartupAudit) enabledUnits() []*startupItem {
+	items := []*startupItem{}
+	seen := map[string]bool{}
+
+	wants, _ := filepath.Glob("/etc/systemd/system/*.wants/*")
+	requires, _ := filepath.Glob("/etc/systemd/system/*.requires/*")
+	local, _ := filepath.Glob("/etc/systemd/system/*.service")
+	links := append(append(wants, requires...), local...)
+	sort.Strings(links)
+
+	for _, link := range links {
+		unit := filepath.Base(link)
+		target, err := filepath.EvalSymlinks(link)
+		// Units linked to /dev/null are masked.
+		if err != nil || target == "/dev/null" || seen[unit] {
+			continue
+		}
+		seen[unit] = true
+
+		items = append(items, &startupItem{kind: startupUnit, path: target, unit: unit})
+	}
+
+	return items
+}
+
+// check fills in the package that owns the item, and the reasons it should be flagged.
+func (s *StartupAudit) check(item *startupItem) {
+	info, err := os.Stat(item.path)
+	if err != nil {
+		return
+	}
+
+	if !item.user {
+		item.pkg = packageOwner(item.path)
+		if item.pkg == "" {
+			item.reasons = append(item.reasons, "not owned by any package")
+		}
+	}
+
+	if time.Since(info.ModTime()) < recentlyModified {
+		item.reasons = append(item.reasons, fmt.Sprintf("modified recently (%s)", info.ModTime().Format(time.DateOnly)))
+	}
+
+	buffer, err := os.ReadFile(item.path)
+	if err != nil {
+		return
+	}
+
+	// Any library in ld.so.preload is loaded into every process, which is rarely legitimate.
+	if item.path == "/etc/ld.so.preload" && strings.TrimSpace(string(buffer)) != "" {
+		item.reasons = append(item.reasons, "preloads libraries into every process")
+	}
+
+	item.reasons = append(item.reasons, persistenceReasons(string(buffer))...)
+}
+
+// review asks the user if the item should be disabled, and disables it.
+func (s *StartupAudit) review(item *startupItem) {
+	name := item.path
+	if item.unit != "" {
+		name = item.unit
+	}
+
+	if !prompts.Confirm(fmt.Sprintf("Should %s be disabled?", name)) {
+		return
+	}
+
+	var err error
+	switch item.kind {
+	case startupUnit:
+		err = RunCommand("systemctl disable --now " + item.unit)
+	case startupInitScript:
+		err = RunCommand("systemctl disable --now " + filepath.Base(item.path))
+	case startupExecutable:
+		var info os.FileInfo
+		if info, err = os.Stat(item.path); err == nil {
+			err = os.Chmod(item.path, info.Mode().Perm()&^0111)
+		}
+	case startupDesktop:
+		var f *utils.INIFile
+		if f, err = utils.LoadINIFile(item.path); err == nil {
+			setINIOption(f, "Desktop Entry", "Hidden", "true")
+			err = f.Save()
+		}
+	case startupDropIn:
+		err = s.disableDropIn(item.path)
+	case startupShellFile:
+		if item.path == "/etc/ld.so.preload" {
+			err = os.Rename(item.path, item.path+".disabled")
+		} else {
+			err = s.commentSuspiciousLines(item.path)
+		}
+	}
+
+	if err != nil {
+		logger.Errorf("unable to disable %s: %s", name, err.Error())
+		return
+	}
+
+	logger.Infof("Disabled %s", name)
+	if item.finding != nil {
+		item.finding.Fixed = true
+	}
+}
+
+// disableDropIn disables a drop-in file. Files under /etc are renamed, since the directories that
+// drop-ins are loaded from only load files with specific extensions. udev rules under /lib belong to
+// packages, which would restore them on upgrade, so they are overridden by a rule with the same name
+// in /etc/udev/rules.d that links to /dev/null instead.
+func (s *StartupAudit) disableDropIn(path string) error {
+	if strings.HasPrefix(path, "/etc/") {
+		return os.Rename(path, path+".disabled")
+	}
+
+	if !strings.HasPrefix(path, "/lib/udev/rules.d/") && !strings.HasPrefix(path, "/usr/lib/udev/rules.d/") {
+		return fmt.Errorf("%s is not in /etc", path)
+	}
+
+	override := filepath.Join("/etc/udev/rules.d", filepath.Base(path))
+	if _, err := os.Lstat(override); err == nil {
+		return fmt.Errorf("%s is already overridden by %s", path, override)
+	}
+
+	if err := os.Symlink("/dev/null", override); err != nil {
+		return err
+	}
+
+	logger.Infof("Overrode %s with %s, which links to /dev/null", path, override)
+	return nil
+}
+
+// commentSuspiciousLines comments out every line in the file that looks malicious.
+func (s *StartupAudit) commentSuspiciousLines(path string) error {
+	buffer, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(buffer), "\n")
+	changed := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") || len(persistenceReasons(line)) == 0 {
+			continue
+		}
+
+		logger.Warnf("%s: commenting out %q", path, line)
+		lines[i] = "# Disabled by Simple-OSHarden: " + line
+		changed = true
+	}
+
+	if !changed {
+		return fmt.Errorf("no suspicious lines were found, so the file was left unchanged")
+	}
+
+	return utils.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644)
+}
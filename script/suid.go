@@ -0,0 +1,212 @@
+package script
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ethaniccc/simple-osharden/prompts"
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+func init() {
+	RegisterScript(&SUIDAudit{})
+}
+
+// expectedSUIDFiles are the setuid and setgid files that are expected on a fresh install of each
+// distro family. Paths use the merged /usr layout, and may be glob patterns.
+var expectedSUIDFiles = map[string][]string{
+	"debian": {
+		"/usr/bin/at", "/usr/bin/bsd-write", "/usr/bin/chage", "/usr/bin/chfn", "/usr/bin/chsh",
+		"/usr/bin/crontab", "/usr/bin/dotlockfile", "/usr/bin/expiry", "/usr/bin/fusermount",
+		"/usr/bin/fusermount3", "/usr/bin/gpasswd", "/usr/bin/mlocate", "/usr/bin/mount",
+		"/usr/bin/newgidmap", "/usr/bin/newgrp", "/usr/bin/newuidmap", "/usr/bin/ntfs-3g",
+		"/usr/bin/passwd", "/usr/bin/pkexec", "/usr/bin/plocate", "/usr/bin/ssh-agent", "/usr/bin/su",
+		"/usr/bin/sudo", "/usr/bin/umount", "/usr/bin/vmware-user-suid-wrapper", "/usr/bin/wall",
+		"/usr/bin/write.ul",
+		"/usr/sbin/mount.cifs", "/usr/sbin/mount.nfs", "/usr/sbin/pam_extrausers_chkpwd",
+		"/usr/sbin/pppd", "/usr/sbin/unix_chkpwd",
+		"/usr/lib/chromium/chrome-sandbox", "/usr/lib/dbus-1.0/dbus-daemon-launch-helper",
+		"/usr/lib/eject/dmcrypt-get-device", "/usr/lib/openssh/ssh-keysign",
+		"/usr/lib/policykit-1/polkit-agent-helper-1", "/usr/lib/polkit-1/polkit-agent-helper-1",
+		"/usr/lib/snapd/snap-confine", "/usr/lib/xorg/Xorg.wrap", "/usr/lib/*/utempter/utempter",
+		"/usr/lib/spice-gtk/spice-client-glib-usb-acl-helper", "/usr/libexec/polkit-agent-helper-1",
+	},
+	"rhel": {
+		"/usr/bin/at", "/usr/bin/chage", "/usr/bin/chfn", "/usr/bin/chsh", "/usr/bin/crontab",
+		"/usr/bin/fusermount", "/usr/bin/fusermount3", "/usr/bin/gpasswd", "/usr/bin/locate",
+		"/usr/bin/mount", "/usr/bin/newgidmap", "/usr/bin/newgrp", "/usr/bin/newuidmap",
+		"/usr/bin/passwd", "/usr/bin/pkexec", "/usr/bin/staprun", "/usr/bin/su", "/usr/bin/sudo",
+		"/usr/bin/umount", "/usr/bin/write",
+		"/usr/sbin/grub2-set-bootflag", "/usr/sbin/lockdev", "/usr/sbin/mount.nfs",
+		"/usr/sbin/pam_timestamp_check", "/usr/sbin/unix_chkpwd", "/usr/sbin/userhelper",
+		"/usr/sbin/usernetctl",
+		"/usr/lib/polkit-1/polkit-agent-helper-1", "/usr/libexec/dbus-1/dbus-daemon-launch-helper",
+		"/usr/libexec/openssh/ssh-keysign", "/usr/libexec/qemu-bridge-helper",
+		"/usr/libexec/utempter/utempter", "/usr/libexec/Xorg.wrap",
+	},
+	"suse": {
+		"/usr/bin/at", "/usr/bin/chage", "/usr/bin/chfn", "/usr/bin/chsh", "/usr/bin/crontab",
+		"/usr/bin/expiry", "/usr/bin/fusermount", "/usr/bin/fusermount3", "/usr/bin/gpasswd",
+		"/usr/bin/mount", "/usr/bin/newgidmap", "/usr/bin/newgrp", "/usr/bin/newuidmap",
+		"/usr/bin/passwd", "/usr/bin/pkexec", "/usr/bin/su", "/usr/bin/sudo", "/usr/bin/umount",
+		"/usr/bin/wall", "/usr/bin/write",
+		"/usr/sbin/mount.nfs", "/usr/sbin/unix2_chkpwd", "/usr/sbin/unix_chkpwd",
+		"/usr/lib/polkit-1/polkit-agent-helper-1", "/usr/lib/utempter/utempter",
+		"/usr/libexec/dbus-1/dbus-daemon-launch-helper", "/usr/libexec/ssh/ssh-keysign",
+		"/usr/libexec/Xorg.wrap",
+	},
+}
+
+// gtfoBins are programs that can be used to escalate privileges if they have the setuid bit.
+// Names ending with * match any version of the program, such as python3.11.
+var gtfoBins = []string{
+	"ash", "awk", "bash", "busybox", "cp", "csh", "dash", "dd", "docker", "ed", "emacs", "env",
+	"expect", "find", "gawk", "gdb", "git", "less", "lua*", "make", "more", "mv", "nano", "nc",
+	"ncat", "nmap", "node", "openssl", "perl*", "php*", "python*", "rsync", "ruby*", "sed", "sh",
+	"socat", "strace", "tar", "taskset", "tclsh*", "tee", "timeout", "vi", "vim*", "wget", "xargs",
+	"zip", "zsh",
+}
+
+// suidFile is a file that has the setuid or setgid bit set.
+type suidFile struct {
+	Path    string
+	Mode    os.FileMode
+	Owner   string
+	Hash    string
+	Package string
+}
+
+// findSUIDFiles walks every local filesystem and returns all the files with the setuid or setgid bit set.
+func findSUIDFiles() []suidFile {
+	names := map[int]string{}
+	if users, err := utils.ReadPasswd("/etc/passwd"); err == nil {
+		for _, u := range users {
+			names[u.UID] = u.Name
+		}
+	}
+
+	files := []suidFile{}
+	// Snaps are mounted read-only from their own images, and their files are checked by snapd instead
+	// of the package manager.
+	walkLocalFiles("/", []string{"/snap"}, func(path string, info os.FileInfo) {
+		if !info.Mode().IsRegular() || info.Mode()&(os.ModeSetuid|os.ModeSetgid) == 0 {
+			return
+		}
+
+		f := suidFile{Path: path, Mode: info.Mode()}
+		if uid, _, ok := utils.FileOwner(info); ok {
+			f.Owner = names[uid]
+			if f.Owner == "" {
+				f.Owner = strconv.Itoa(uid)
+			}
+		}
+
+		files = append(files, f)
+	})
+
+	return files
+}
+
+// SUIDAudit is a script that finds every setuid and setgid file on the machine and compares them to a
+// list of files that are expected on the running distro. The user is able to strip the setuid and
+// setgid bits from any unexpected files.
+type SUIDAudit struct {
+}
+
+func (s *SUIDAudit) Name() string {
+	return "suidaudit"
+}
+
+func (s *SUIDAudit) Description() string {
+	return "Audits setuid and setgid files against a known-good baseline."
+}
+
+func (s *SUIDAudit) RunOnLinux() error {
+	family := distroFamily()
+	expected, ok := expectedSUIDFiles[family]
+	if !ok {
+		logger.Warn("Unknown distro, every setuid and setgid file will be treated as unexpected")
+	}
+
+	logger.Info("Searching for setuid and setgid files, this may take a while...")
+	report := &Report{}
+	for _, f := range findSUIDFiles() {
+		hash, err := hashFile(f.Path)
+		if err != nil {
+			logger.Warnf("unable to hash %s: %s", f.Path, err.Error())
+		}
+		f.Hash = hash
+		f.Package = packageOwner(f.Path)
+
+		pkg := f.Package
+		if pkg == "" {
+			pkg = "no package"
+		}
+
+		logger.Infof("%s %s owner=%s package=%s sha256=%s", f.Mode, f.Path, f.Owner, pkg, f.Hash)
+
+		reasons := []string{}
+		if isGTFOBin(f.Path) {
+			reasons = append(reasons, "can be used to escalate privileges (GTFOBins)")
+		} else if !matchesAnyPath(f.Path, expected) {
+			reasons = append(reasons, "not expected to be setuid or setgid")
+		}
+
+		if f.Package == "" {
+			reasons = append(reasons, "not owned by any package")
+		}
+
+		if len(reasons) == 0 {
+			continue
+		}
+
+		finding := report.Flag(f.Path, strings.Join(reasons, ", "))
+		if !prompts.Confirm(fmt.Sprintf("Should the setuid and setgid bits be removed from %s?", f.Path)) {
+			continue
+		}
+
+		if err := os.Chmod(f.Path, f.Mode.Perm()|f.Mode&os.ModeSticky); err != nil {
+			logger.Errorf("unable to change the mode of %s: %s", f.Path, err.Error())
+			continue
+		}
+
+		finding.Fixed = true
+	}
+
+	report.Summarize()
+	return nil
+}
+
+// isGTFOBin returns true if the file is a program that can be used to escalate privileges.
+func isGTFOBin(path string) bool {
+	name := filepath.Base(path)
+	for _, bin := range gtfoBins {
+		if name == bin || (strings.HasSuffix(bin, "*") && strings.HasPrefix(name, strings.TrimSuffix(bin, "*"))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesAnyPath returns true if the path matches any of the given patterns. Paths under /bin, /sbin
+// and /lib are matched as if they were under /usr.
+func matchesAnyPath(path string, patterns []string) bool {
+	for _, prefix := range []string{"/bin/", "/sbin/", "/lib/", "/lib64/"} {
+		if strings.HasPrefix(path, prefix) {
+			path = "/usr" + path
+			break
+		}
+	}
+
+	for _, pattern := range patterns {
+		if ok, _ := filepath.Match(pattern, path); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,368 @@
+package script
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ethaniccc/simple-osharden/prompts"
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+// timeSyncMarker is the line that TimeSync adds before its own lines at the end of a config file.
+const timeSyncMarker = "# Generated by Simple-OSHarden. Lines below this one are replaced by timesync."
+
+// timeDaemon is a daemon that can keep the system time synchronized.
+type timeDaemon struct {
+	Name string
+	// Units are the names the daemon's systemd unit has on different distros.
+	Units []string
+	// Configs are the paths the daemon's config file has on different distros.
+	Configs []string
+}
+
+var (
+	chrony    = timeDaemon{Name: "chrony", Units: []string{"chrony", "chronyd"}, Configs: []string{"/etc/chrony/chrony.conf", "/etc/chrony.conf"}}
+	timesyncd = timeDaemon{Name: "timesyncd", Units: []string{"systemd-timesyncd"}, Configs: []string{"/etc/systemd/timesyncd.conf"}}
+	ntpd      = timeDaemon{Name: "ntpd", Units: []string{"ntp", "ntpd", "ntpsec"}, Configs: []string{"/etc/ntp.conf", "/etc/ntpsec/ntp.conf"}}
+)
+
+// unit returns the name of the daemon's systemd unit on this machine, or an empty string if the
+// daemon is not installed.
+func (d timeDaemon) unit() string {
+	for _, unit := range d.Units {
+		if _, err := GetCommandOutput("systemctl cat " + unit + ".service"); err == nil {
+			return unit
+		}
+	}
+
+	return ""
+}
+
+// config returns the path of the daemon's config file on this machine.
+func (d timeDaemon) config() string {
+	for _, path := range d.Configs {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+
+	return d.Configs[0]
+}
+
+func init() {
+	RegisterScript(&TimeSync{})
+}
+
+// TimeSync is a script that makes sure exactly one daemon is keeping the system time synchronized,
+// since logs and authentication protocols such as Kerberos rely on the time being accurate.
+type TimeSync struct {
+}
+
+func (s *TimeSync) Name() string {
+	return "timesync"
+}
+
+func (s *TimeSync) Description() string {
+	return "Sets up time synchronization with a single NTP daemon."
+}
+
+func (s *TimeSync) RunOnLinux() error {
+	installed := []timeDaemon{}
+	names := []string{}
+	for _, d := range []timeDaemon{chrony, timesyncd, ntpd} {
+		if d.unit() != "" {
+			installed = append(installed, d)
+			names = append(names, d.Name)
+		}
+	}
+
+	if len(installed) == 0 {
+		return fmt.Errorf("no time daemon is installed, install chrony first")
+	}
+
+	daemon := installed[0]
+	if len(installed) > 1 {
+		name := prompts.RawResponseWithDefaultPrompt(fmt.Sprintf("Which time daemon should be used (%s)? (recommended is %s)", strings.Join(names, ", "), daemon.Name), daemon.Name)
+		found := false
+		for _, d := range installed {
+			if d.Name == name {
+				daemon, found = d, true
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("%s is not installed", name)
+		}
+	}
+
+	// Daemons fight over the clock if more than one of them is running.
+	for _, d := range installed {
+		if d.Name == daemon.Name {
+			continue
+		}
+
+		unit := d.unit()
+		if _, err := GetCommandOutput("systemctl is-active " + unit); err == nil {
+			logger.Warnf("Disabling %s, since only %s should be running", unit, daemon.Name)
+		}
+		RunCommand("systemctl disable --now " + unit)
+	}
+
+	servers := strings.Fields(prompts.RawResponseWithDefaultPrompt("Which NTP servers should be used, separated by spaces? (default is 0.pool.ntp.org 1.pool.ntp.org 2.pool.ntp.org)", "0.pool.ntp.org 1.pool.ntp.org 2.pool.ntp.org"))
+	if len(servers) == 0 {
+		return fmt.Errorf("no NTP servers were given")
+	}
+
+	var err error
+	switch daemon.Name {
+	case chrony.Name:
+		err = s.configureChrony(daemon.config(), servers)
+	case ntpd.Name:
+		err = s.configureNTPd(daemon.config(), servers)
+	case timesyncd.Name:
+		err = s.configureTimesyncd(daemon.config(), servers)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	unit := daemon.unit()
+	cmds := []LoggedCommand{
+		{"Enabling " + unit, "systemctl enable " + unit, false},
+		{"Restarting " + unit, "systemctl restart " + unit, false},
+	}
+
+	// timedatectl set-ntp starts whichever daemon the distro prefers, which may not be the chosen one.
+	if daemon.Name == timesyncd.Name {
+		cmds = append(cmds, LoggedCommand{"Enabling NTP synchronization", "timedatectl set-ntp true", true})
+	}
+
+	if err := ExecuteLoggedCommands(cmds); err != nil {
+		return err
+	}
+
+	if daemon.Name == chrony.Name {
+		s.chronyStatus()
+	} else {
+		s.timedatectlStatus()
+	}
+
+	return nil
+}
+
+// configureChrony replaces the servers in the chrony config, and stops chrony from serving time to
+// other machines.
+func (s *TimeSync) configureChrony(file string, servers []string) error {
+	lines, err := s.readConfig(file)
+	if err != nil {
+		return err
+	}
+
+	// Servers can also be defined in the .sources files of the directories listed in sourcedir
+	// directives, such as /etc/chrony/sources.d on Debian.
+	current := chronyServers(lines)
+	for _, source := range chronySourceFiles(lines) {
+		sourceLines, err := s.readConfig(source)
+		if err != nil {
+			logger.Warn(err.Error())
+			continue
+		}
+
+		current = append(current, chronyServers(sourceLines)...)
+		// Sources outside /etc are written at runtime, such as the servers given by DHCP.
+		if !strings.HasPrefix(source, "/etc/") {
+			if servers := chronyServers(sourceLines); len(servers) != 0 {
+				logger.Warnf("%s also adds %s, it is managed by another program", source, strings.Join(servers, ", "))
+			}
+			continue
+		}
+
+		if err := s.writeConfig(source, commentOutDirectives(source, sourceLines, "server", "pool", "peer")); err != nil {
+			return err
+		}
+	}
+
+	if len(current) == 0 {
+		logger.Info("chrony has no servers configured")
+	} else {
+		logger.Infof("chrony is currently using %s", strings.Join(current, ", "))
+	}
+
+	// chrony only serves time to the clients listed in allow directives, and port 0 closes the NTP
+	// port completely.
+	lines = commentOutDirectives(file, lines, "server", "pool", "peer", "allow", "port")
+	lines = append(lines, timeSyncMarker)
+	for _, server := range servers {
+		lines = append(lines, fmt.Sprintf("server %s iburst", server))
+	}
+	lines = append(lines, "port 0")
+
+	return s.writeConfig(file, lines)
+}
+
+// configureNTPd replaces the servers in the ntpd config, and only lets the configured servers talk
+// to ntpd, so that it does not serve time to other machines.
+func (s *TimeSync) configureNTPd(file string, servers []string) error {
+	lines, err := s.readConfig(file)
+	if err != nil {
+		return err
+	}
+
+	lines = commentOutDirectives(file, lines, "server", "pool", "peer", "restrict")
+	lines = append(lines,
+		timeSyncMarker,
+		"restrict default ignore",
+		"restrict -6 default ignore",
+		"restrict 127.0.0.1",
+		"restrict ::1",
+		// Lets the configured servers answer, without allowing them to change the configuration.
+		"restrict source nomodify notrap noquery",
+	)
+	for _, server := range servers {
+		lines = append(lines, fmt.Sprintf("server %s iburst", server))
+	}
+
+	return s.writeConfig(file, lines)
+}
+
+// configureTimesyncd sets the servers used by systemd-timesyncd. timesyncd is only a client, so it
+// never serves time to other machines.
+func (s *TimeSync) configureTimesyncd(file string, servers []string) error {
+	f, err := utils.LoadINIFile(file)
+	if err != nil {
+		return err
+	}
+
+	setINIOption(f, "Time", "NTP", strings.Join(servers, " "))
+	return f.Save()
+}
+
+// readConfig returns the lines of the config file, without the lines added by an earlier run.
+func (s *TimeSync) readConfig(file string) ([]string, error) {
+	buffer, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", file, err.Error())
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(buffer), "\n"), "\n")
+	for i, line := range lines {
+		if line == timeSyncMarker {
+			return lines[:i], nil
+		}
+	}
+
+	return lines, nil
+}
+
+func (s *TimeSync) writeConfig(file string, lines []string) error {
+	if err := utils.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("unable to write to %s: %s", file, err.Error())
+	}
+
+	return nil
+}
+
+// chronyStatus logs the synchronization status reported by chronyc tracking.
+func (s *TimeSync) chronyStatus() {
+	out, err := GetCommandOutput("chronyc tracking")
+	if err != nil {
+		logger.Warnf("unable to get the status of chrony: %s", err.Error())
+		return
+	}
+
+	// Each line is a field name and its value, such as "Leap status     : Normal".
+	status := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		split := strings.SplitN(line, ":", 2)
+		if len(split) == 2 {
+			status[strings.TrimSpace(split[0])] = strings.TrimSpace(split[1])
+		}
+	}
+
+	if status["Leap status"] != "Normal" {
+		logger.Warnf("The time is not synchronized yet (%s), this can take a few minutes", status["Leap status"])
+		return
+	}
+
+	logger.Infof("The time is synchronized with %s (stratum %s), the offset is %s", status["Reference ID"], status["Stratum"], status["System time"])
+}
+
+// timedatectlStatus logs the synchronization status reported by timedatectl.
+func (s *TimeSync) timedatectlStatus() {
+	out, err := GetCommandOutput("timedatectl show")
+	if err != nil {
+		logger.Warnf("unable to get the time synchronization status: %s", err.Error())
+		return
+	}
+
+	status := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		split := strings.SplitN(line, "=", 2)
+		if len(split) == 2 {
+			status[split[0]] = split[1]
+		}
+	}
+
+	if status["NTPSynchronized"] != "yes" {
+		logger.Warn("The time is not synchronized yet, this can take a few minutes")
+		return
+	}
+
+	logger.Infof("The time is synchronized (timezone %s)", status["Timezone"])
+}
+
+// chronyServers returns the servers, pools and peers in the lines of a chrony config.
+func chronyServers(lines []string) []string {
+	servers := []string{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && (fields[0] == "server" || fields[0] == "pool" || fields[0] == "peer") {
+			servers = append(servers, fields[1])
+		}
+	}
+
+	return servers
+}
+
+// chronySourceFiles returns the .sources files in the directories listed in the sourcedir directives
+// of a chrony config.
+func chronySourceFiles(lines []string) []string {
+	files := []string{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "sourcedir" {
+			continue
+		}
+
+		matches, _ := filepath.Glob(filepath.Join(fields[1], "*.sources"))
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+
+	return files
+}
+
+// commentOutDirectives comments out every line in a config file that starts with one of the given
+// directives, and logs each line that is commented out.
+func commentOutDirectives(file string, lines []string, directives ...string) []string {
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		for _, directive := range directives {
+			if fields[0] == directive {
+				logger.Warnf("%s: commenting out %q", file, strings.TrimSpace(line))
+				lines[i] = "#" + line
+				break
+			}
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,189 @@
+package script
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ethaniccc/simple-osharden/prompts"
+	"github.com/ethaniccc/simple-osharden/utils"
+)
+
+// umaskLine matches a line in a shell startup file that sets the umask.
+var umaskLine = regexp.MustCompile(`^\s*umask\s+([0-7]+)\b`)
+
+// umaskValue matches a valid octal umask, such as 027 or 0027.
+var umaskValue = regexp.MustCompile(`^[0-7]{3,4}$`)
+
+func init() {
+	RegisterScript(&UmaskPolicy{})
+}
+
+// umaskSetting is a umask value set in a startup file.
+type umaskSetting struct {
+	file  string
+	value string
+}
+
+// UmaskPolicy is a script that sets a restrictive default umask for every user, and logs out shells
+// that have been idle for too long.
+type UmaskPolicy struct {
+}
+
+func (s *UmaskPolicy) Name() string {
+	return "umaskpolicy"
+}
+
+func (s *UmaskPolicy) Description() string {
+	return "Sets the default umask and shell idle timeout."
+}
+
+func (s *UmaskPolicy) RunOnLinux() error {
+	umask := prompts.ValidResponseWithDefaultPrompt("What should the default umask be, as 3 or 4 octal digits? (recommended is 027)", "027", umaskValue.MatchString)
+	timeout := prompts.ValidResponseWithDefaultPrompt("How many seconds can a shell be idle before it is logged out? (recommended is 900)", "900", func(s string) bool {
+		n, err := strconv.Atoi(s)
+		return err == nil && n > 0
+	})
+
+	// login.defs separates options from their values with tabs on Debian, so the existing UMASK line
+	// is replaced no matter how it is spaced.
+	if err := utils.SetDirectiveInFile("UMASK", umask, "\t\t", "/etc/login.defs"); err != nil {
+		return err
+	}
+
+	if err := utils.WriteFile("/etc/profile.d/osharden-umask.sh", []byte(fmt.Sprintf("# Generated by Simple-OSHarden.\numask %s\n", umask)), 0644); err != nil {
+		return fmt.Errorf("unable to write to /etc/profile.d/osharden-umask.sh: %s", err.Error())
+	}
+
+	// TMOUT is made read-only so users can not turn the timeout off in their own shells. The file can be
+	// sourced more than once in the same shell, where setting TMOUT again would fail.
+	tmout := fmt.Sprintf("# Generated by Simple-OSHarden.\nif [ \"$(readonly -p | grep -c ' TMOUT=')\" = 0 ]; then\n\tTMOUT=%s\n\treadonly TMOUT\n\texport TMOUT\nfi\n", timeout)
+	if err := utils.WriteFile("/etc/profile.d/osharden-tmout.sh", []byte(tmout), 0644); err != nil {
+		return fmt.Errorf("unable to write to /etc/profile.d/osharden-tmout.sh: %s", err.Error())
+	}
+
+	// Non-login shells read /etc/bash.bashrc instead of /etc/profile.d.
+	if err := s.setBashrcUmask("/etc/bash.bashrc", umask); err != nil {
+		return err
+	}
+
+	return s.audit(umask)
+}
+
+// setBashrcUmask replaces any umask lines in the file with the given umask, or adds one if there are none.
+func (s *UmaskPolicy) setBashrcUmask(file, umask string) error {
+	buffer, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to read %s: %s", file, err.Error())
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(buffer), "\n"), "\n")
+	found := false
+	for i, line := range lines {
+		if umaskLine.MatchString(line) {
+			lines[i] = "umask " + umask
+			found = true
+		}
+	}
+
+	if !found {
+		lines = append(lines, "umask "+umask)
+	}
+
+	if err := utils.WriteFile(file, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("unable to write to %s: %s", file, err.Error())
+	}
+
+	return nil
+}
+
+// audit reports every startup file that sets a different umask, and which setting wins for each user.
+func (s *UmaskPolicy) audit(umask string) error {
+	// Files are listed in the order a login shell reads them, so the last umask found is the one used.
+	// /etc/profile sources /etc/bash.bashrc before the scripts in /etc/profile.d.
+	system := []string{"/etc/profile", "/etc/bash.bashrc"}
+	dropIns, _ := filepath.Glob("/etc/profile.d/*.sh")
+	sort.Strings(dropIns)
+	system = append(system, dropIns...)
+
+	// pam_umask applies the UMASK from login.defs before any shell starts. If it is set more than
+	// once, the last value is used.
+	settings := []umaskSetting{}
+	if value, ok, err := utils.GetDirectiveFromFile("UMASK", " ", "/etc/login.defs"); err == nil && ok {
+		settings = []umaskSetting{{file: "/etc/login.defs", value: value}}
+	}
+
+	for _, file := range system {
+		settings = append(settings, s.umaskSettings(file)...)
+	}
+
+	report := &Report{}
+	for _, setting := range settings {
+		if setting.value != umask {
+			report.Flag(setting.file, fmt.Sprintf("sets a conflicting umask of %s", setting.value))
+		}
+	}
+
+	if len(settings) != 0 {
+		winner := settings[len(settings)-1]
+		logger.Infof("The system-wide umask is %s, set by %s", winner.value, winner.file)
+	}
+
+	users, err := humanUsers()
+	if err != nil {
+		return err
+	}
+
+	for _, u := range users {
+		// Bash only reads the first of these files that exists.
+		userFiles := []string{}
+		for _, name := range []string{".bash_profile", ".bash_login", ".profile"} {
+			if _, err := os.Stat(filepath.Join(u.Home, name)); err == nil {
+				userFiles = append(userFiles, filepath.Join(u.Home, name))
+				break
+			}
+		}
+		userFiles = append(userFiles, filepath.Join(u.Home, ".bashrc"))
+
+		userSettings := append([]umaskSetting{}, settings...)
+		for _, file := range userFiles {
+			for _, setting := range s.umaskSettings(file) {
+				userSettings = append(userSettings, setting)
+				if setting.value != umask {
+					report.Flag(setting.file, fmt.Sprintf("sets a conflicting umask of %s for %s", setting.value, u.Name))
+				}
+			}
+		}
+
+		if len(userSettings) != 0 {
+			winner := userSettings[len(userSettings)-1]
+			logger.Infof("The umask of %s is %s, set by %s", u.Name, winner.value, winner.file)
+		}
+	}
+
+	report.Summarize()
+	return nil
+}
+
+// umaskSettings returns every umask set in the given file, in order.
+func (s *UmaskPolicy) umaskSettings(file string) []umaskSetting {
+	buffer, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+
+	settings := []umaskSetting{}
+	for _, line := range strings.Split(string(buffer), "\n") {
+		if m := umaskLine.FindStringSubmatch(line); m != nil {
+			settings = append(settings, umaskSetting{file: file, value: m[1]})
+		}
+	}
+
+	return settings
+}
@@ -3,9 +3,12 @@ package script
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/ethaniccc/simple-osharden/prompts"
+	"github.com/ethaniccc/simple-osharden/utils"
 )
 
 func init() {
@@ -127,7 +130,8 @@ func (s *VerifyUsers) RunOnWindows() error {
 	return nil
 }
 
-// DisableRoot is a script that disables the root user on the machine.
+// DisableRoot is a script that disables the root user on the machine. The root password is locked,
+// root logins are limited to the console, root cannot log in over SSH, and su is limited to a group.
 type DisableRoot struct {
 }
 
@@ -136,10 +140,311 @@ func (s *DisableRoot) Name() string {
 }
 
 func (s *DisableRoot) Description() string {
-	return "Disables the root user on the machine."
+	return "Disables and locks down the root user on the machine."
 }
 
 func (s *DisableRoot) RunOnLinux() error {
-	RunCommand("passwd -l root")
+	group := prompts.RawResponseWithDefaultPrompt("Which group should be allowed to use su? (recommended is sugroup, which has no members)", "sugroup")
+
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"Lock the root password", s.lockPassword},
+		{"Restrict root logins to the console", s.restrictConsoleLogin},
+		{"Disable root login over SSH", s.disableSSHLogin},
+		{fmt.Sprintf("Restrict su to the %s group", group), func() error {
+			return s.restrictSu(group)
+		}},
+	}
+
+	failed := 0
+	for _, step := range steps {
+		logger.Info(step.name)
+		if err := step.run(); err != nil {
+			logger.Errorf("[FAILED] %s: %s", step.name, err.Error())
+			failed++
+			continue
+		}
+
+		logger.Infof("[OK] %s", step.name)
+	}
+
+	if failed != 0 {
+		return fmt.Errorf("%d of %d steps failed", failed, len(steps))
+	}
+
 	return nil
 }
+
+// lockPassword locks the root password, and verifies the lock is present in /etc/shadow.
+func (s *DisableRoot) lockPassword() error {
+	if err := RunCommand("passwd -l root"); err != nil {
+		return fmt.Errorf("unable to lock root: %s", err.Error())
+	}
+
+	buffer, err := os.ReadFile("/etc/shadow")
+	if err != nil {
+		return fmt.Errorf("unable to read /etc/shadow: %s", err.Error())
+	}
+
+	for _, line := range strings.Split(string(buffer), "\n") {
+		split := strings.Split(line, ":")
+		if len(split) < 2 || split[0] != "root" {
+			continue
+		}
+
+		// A locked password starts with "!", and "*" means no password can ever match.
+		if !strings.HasPrefix(split[1], "!") && !strings.HasPrefix(split[1], "*") {
+			return fmt.Errorf("the root password is still usable in /etc/shadow")
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("root is not listed in /etc/shadow")
+}
+
+// restrictConsoleLogin only allows root to log in on the console TTYs, using pam_securetty.
+func (s *DisableRoot) restrictConsoleLogin() error {
+	modules := []string{}
+	for _, pattern := range []string{"/lib/*/security/pam_securetty.so", "/usr/lib/*/security/pam_securetty.so", "/lib64/security/pam_securetty.so", "/usr/lib64/security/pam_securetty.so"} {
+		matches, _ := filepath.Glob(pattern)
+		modules = append(modules, matches...)
+	}
+
+	if len(modules) == 0 {
+		return fmt.Errorf("pam_securetty is not installed on this machine")
+	}
+
+	if err := s.writeSecureTTYs(); err != nil {
+		return err
+	}
+
+	buffer, err := os.ReadFile("/etc/pam.d/login")
+	if err != nil {
+		return fmt.Errorf("unable to read /etc/pam.d/login: %s", err.Error())
+	}
+
+	lines := strings.Split(string(buffer), "\n")
+	if pamHasModule(lines, "auth", "pam_securetty.so") {
+		return nil
+	}
+
+	// Add pam_securetty before any other auth module, so it is always checked.
+	insert := len(lines)
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "auth") {
+			insert = i
+			break
+		}
+	}
+
+	lines = append(lines[:insert], append([]string{"auth       requisite  pam_securetty.so"}, lines[insert:]...)...)
+	if err := utils.WriteFile("/etc/pam.d/login", []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		return fmt.Errorf("unable to write to /etc/pam.d/login: %s", err.Error())
+	}
+
+	if buffer, err = os.ReadFile("/etc/pam.d/login"); err != nil || !pamHasModule(strings.Split(string(buffer), "\n"), "auth", "pam_securetty.so") {
+		return fmt.Errorf("pam_securetty is still not used in /etc/pam.d/login")
+	}
+
+	return nil
+}
+
+// writeSecureTTYs adds the console TTYs to /etc/securetty, keeping the TTYs already listed in it, such
+// as serial consoles that may be the only way to reach the machine. The file is backed up first.
+func (s *DisableRoot) writeSecureTTYs() error {
+	ttys := []string{"console", "tty1", "tty2", "tty3", "tty4", "tty5", "tty6"}
+	lines := []string{}
+	if buffer, err := os.ReadFile("/etc/securetty"); err == nil {
+		backup, err := utils.BackupFile("/etc/securetty")
+		if err != nil {
+			return fmt.Errorf("unable to back up /etc/securetty: %s", err.Error())
+		}
+		logger.Infof("Backed up /etc/securetty to %s", backup)
+
+		lines = strings.Split(strings.TrimSuffix(string(buffer), "\n"), "\n")
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("unable to read /etc/securetty: %s", err.Error())
+	}
+
+	for _, tty := range ttys {
+		if !s.listsTTY(lines, tty) {
+			lines = append(lines, tty)
+		}
+	}
+
+	if err := utils.WriteFile("/etc/securetty", []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		return fmt.Errorf("unable to write to /etc/securetty: %s", err.Error())
+	}
+
+	buffer, err := os.ReadFile("/etc/securetty")
+	if err != nil {
+		return fmt.Errorf("unable to read /etc/securetty: %s", err.Error())
+	}
+
+	lines = strings.Split(string(buffer), "\n")
+	for _, tty := range ttys {
+		if !s.listsTTY(lines, tty) {
+			return fmt.Errorf("%s is still not listed in /etc/securetty", tty)
+		}
+	}
+
+	return nil
+}
+
+// listsTTY returns true if the lines of /etc/securetty list the TTY.
+func (s *DisableRoot) listsTTY(lines []string, tty string) bool {
+	for _, line := range lines {
+		if strings.TrimSpace(line) == tty {
+			return true
+		}
+	}
+
+	return false
+}
+
+// disableSSHLogin sets PermitRootLogin to no in the SSH server config, and checks that sshd uses it.
+func (s *DisableRoot) disableSSHLogin() error {
+	if !sshdInstalled() {
+		logger.Info("The SSH server is not installed, skipping")
+		return nil
+	}
+
+	return setSSHDOption("PermitRootLogin", "no")
+}
+
+// restrictSu only allows members of the given group to use su, using pam_wheel.
+func (s *DisableRoot) restrictSu(group string) error {
+	if _, err := GetCommandOutput("getent group " + group); err != nil {
+		logger.Warnf("Creating the %s group", group)
+		if err := RunCommand("groupadd " + group); err != nil {
+			return fmt.Errorf("unable to create group %s: %s", group, err.Error())
+		}
+	}
+
+	buffer, err := os.ReadFile("/etc/pam.d/su")
+	if err != nil {
+		return fmt.Errorf("unable to read /etc/pam.d/su: %s", err.Error())
+	}
+
+	rule := "auth       required   pam_wheel.so use_uid group=" + group
+	lines := strings.Split(string(buffer), "\n")
+	index, commented, rootOk := -1, -1, -1
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		isComment := strings.HasPrefix(trimmed, "#")
+		fields := strings.Fields(strings.TrimLeft(trimmed, "#"))
+		if len(fields) < 3 || fields[0] != "auth" {
+			continue
+		}
+
+		// Lines with deny forbid the group from using su instead, such as the example for the nosu group.
+		wheel := fields[1] == "required" && fields[2] == "pam_wheel.so" && !strings.Contains(line, "deny")
+		if wheel && !isComment {
+			index = i
+		} else if wheel && commented == -1 {
+			commented = i
+		} else if !isComment && fields[2] == "pam_rootok.so" {
+			rootOk = i
+		}
+	}
+
+	if index == -1 {
+		index = commented
+	}
+
+	if index != -1 {
+		lines[index] = rule
+	} else if rootOk != -1 {
+		lines = append(lines[:rootOk+1], append([]string{rule}, lines[rootOk+1:]...)...)
+	} else {
+		// pam_wheel has to come after pam_rootok, otherwise root itself is only allowed to use su if it
+		// is in the group.
+		return fmt.Errorf("/etc/pam.d/su does not use pam_rootok.so, add pam_wheel.so after it by hand")
+	}
+
+	if err := utils.WriteFile("/etc/pam.d/su", []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		return fmt.Errorf("unable to write to /etc/pam.d/su: %s", err.Error())
+	}
+
+	verified := false
+	if buffer, err = os.ReadFile("/etc/pam.d/su"); err == nil {
+		for _, line := range strings.Split(string(buffer), "\n") {
+			verified = verified || strings.TrimSpace(line) == rule
+		}
+	}
+
+	if !verified {
+		return fmt.Errorf("pam_wheel is still not required in /etc/pam.d/su")
+	}
+
+	return nil
+}
+
+// pamHasModule returns true if the lines of a PAM config use the module for the given type, such as
+// "auth", on a line that is not commented out.
+func pamHasModule(lines []string, moduleType, module string) bool {
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[0] != moduleType {
+			continue
+		}
+
+		for _, field := range fields[2:] {
+			if field == module || strings.HasSuffix(field, "/"+module) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// humanUsers returns the accounts in /etc/passwd that belong to people rather than system
+// services. An account is considered human if its uid is at least UID_MIN from /etc/login.defs
+// and it has a login shell.
+func humanUsers() ([]utils.PasswdEntry, error) {
+	users, err := utils.ReadPasswd("/etc/passwd")
+	if err != nil {
+		return nil, err
+	}
+
+	minUID := uidMin()
+	humans := []utils.PasswdEntry{}
+	for _, user := range users {
+		// 65534 is the uid of the nobody user.
+		if user.UID < minUID || user.UID == 65534 {
+			continue
+		}
+
+		if strings.HasSuffix(user.Shell, "nologin") || strings.HasSuffix(user.Shell, "false") {
+			continue
+		}
+
+		humans = append(humans, user)
+	}
+
+	return humans, nil
+}
+
+// uidMin returns the lowest uid given to accounts that belong to people, which is UID_MIN from
+// /etc/login.defs or 1000 if it is not set.
+func uidMin() int {
+	uid := 1000
+	if buffer, err := os.ReadFile("/etc/login.defs"); err == nil {
+		for _, line := range strings.Split(string(buffer), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 || fields[0] != "UID_MIN" {
+				continue
+			}
+
+			if v, err := strconv.Atoi(fields[1]); err == nil {
+				uid = v
+			}
+		}
+	}
+
+	return uid
+}
@@ -69,7 +69,7 @@ func WriteOptsToFile(opts map[string]string, sep string, file string) error {
 	}
 
 	// Write to the file.
-	if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+	if err := WriteFile(file, []byte(strings.Join(lines, "\n")), 0644); err != nil {
 		return fmt.Errorf("unable to write to %s: %s", file, err.Error())
 	}
 
@@ -103,9 +103,86 @@ func DelOptsFromFile(opts []string, sep string, file string) error {
 	}
 
 	// Write to the file.
-	if err := os.WriteFile(file, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+	if err := WriteFile(file, []byte(strings.Join(lines, "\n")), 0644); err != nil {
 		return fmt.Errorf("unable to write to %s: %s", file, err.Error())
 	}
 
 	return nil
 }
+
+// GetDirectiveFromFile will return the value of the directive in the given file, ignoring commented out
+// lines. If the directive is set more than once, the last value is returned. If sep is a space, the
+// directive and its value can be separated by any number of spaces or tabs.
+func GetDirectiveFromFile(key string, sep string, file string) (string, bool, error) {
+	buffer, err := os.ReadFile(file)
+	if err != nil {
+		return "", false, fmt.Errorf("unable to read %s: %s", file, err.Error())
+	}
+
+	value, found := "", false
+	for _, line := range strings.Split(string(buffer), "\n") {
+		if k, v, ok := splitDirective(line, sep); ok && k == key {
+			value, found = v, true
+		}
+	}
+
+	return value, found, nil
+}
+
+// SetDirectiveInFile will set the directive in the given file, replacing every uncommented line that
+// sets it. The directive is added to the end of the file if no line sets it. If sep is a space, the
+// directive and its value can be separated by any number of spaces or tabs.
+func SetDirectiveInFile(key string, value string, sep string, file string) error {
+	buffer, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("unable to read %s: %s", file, err.Error())
+	}
+
+	lines := strings.Split(string(buffer), "\n")
+	found := false
+	for i, line := range lines {
+		if k, _, ok := splitDirective(line, sep); ok && k == key {
+			lines[i] = key + sep + value
+			found = true
+		}
+	}
+
+	if !found {
+		// Keep the newline at the end of the file after the added line.
+		if len(lines) != 0 && lines[len(lines)-1] == "" {
+			lines = append(lines[:len(lines)-1], key+sep+value, "")
+		} else {
+			lines = append(lines, key+sep+value)
+		}
+	}
+
+	if err := WriteFile(file, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		return fmt.Errorf("unable to write to %s: %s", file, err.Error())
+	}
+
+	return nil
+}
+
+// splitDirective splits an uncommented config line into its directive and value.
+func splitDirective(line string, sep string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", "", false
+	}
+
+	if strings.TrimSpace(sep) == "" {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return "", "", false
+		}
+
+		return fields[0], strings.TrimSpace(strings.TrimPrefix(line, fields[0])), true
+	}
+
+	split := strings.SplitN(line, sep, 2)
+	if len(split) != 2 {
+		return "", "", false
+	}
+
+	return strings.TrimSpace(split[0]), strings.TrimSpace(split[1]), true
+}
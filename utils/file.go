@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WriteFile writes data to the given file, keeping the mode and owner of the file if it already exists.
+// The data is written to a temporary file that is renamed over the original, so a partially written
+// file is never left behind. The extended attributes of the original file, such as its SELinux label,
+// are kept as well. The given permissions are only used if the file does not exist yet.
+func WriteFile(file string, data []byte, perm os.FileMode) error {
+	// Write through symlinks (such as /etc/resolv.conf) instead of replacing them.
+	if target, err := filepath.EvalSymlinks(file); err == nil {
+		file = target
+	}
+
+	mode, uid, gid, exists := perm, -1, -1, false
+	if info, err := os.Stat(file); err == nil {
+		exists = true
+		mode = info.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		if u, g, ok := FileOwner(info); ok {
+			uid, gid = u, g
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	// The temporary file gets the default SELinux label of the directory, so the label and ACLs of the
+	// original file are copied over before it is replaced.
+	if exists {
+		copyXattrs(file, tmp.Name())
+	}
+
+	// chown clears the setuid and setgid bits, so the mode is set last.
+	if uid != -1 {
+		if err := os.Chown(tmp.Name(), uid, gid); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), file)
+}
+
+// BackupFile copies the file to the same path with a .osharden.bak suffix, and returns the path of
+// the copy. If a backup already exists it is left alone, so that it always holds the file as it was
+// before it was first changed.
+func BackupFile(file string) (string, error) {
+	backup := file + ".osharden.bak"
+	if _, err := os.Stat(backup); err == nil {
+		return backup, nil
+	}
+
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	return backup, WriteFile(backup, data, info.Mode().Perm())
+}
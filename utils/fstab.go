@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// FstabFile is an fstab file that can be edited without changing its comments, formatting or any
+// entries that are not edited.
+type FstabFile struct {
+	path  string
+	lines []string
+}
+
+// LoadFstab will load the given fstab file.
+func LoadFstab(file string) (*FstabFile, error) {
+	buffer, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", file, err.Error())
+	}
+
+	return &FstabFile{path: file, lines: strings.Split(string(buffer), "\n")}, nil
+}
+
+// Find returns the entry for the given mount point. The last return value is false if there is no
+// entry for the mount point.
+func (f *FstabFile) Find(mountPoint string) (MountEntry, bool) {
+	if i := f.index(mountPoint); i != -1 {
+		entry, _ := parseMountLine(f.lines[i])
+		return entry, true
+	}
+
+	return MountEntry{}, false
+}
+
+// SetOptions replaces the options of the entry for the given mount point. Only the options field of
+// the line is changed, so the rest of the line keeps its original formatting. It returns false if
+// there is no entry for the mount point.
+func (f *FstabFile) SetOptions(mountPoint string, opts []string) bool {
+	i := f.index(mountPoint)
+	if i == -1 {
+		return false
+	}
+
+	f.lines[i] = replaceField(f.lines[i], 3, strings.Join(opts, ","))
+	return true
+}
+
+// Add adds a new entry to the end of the file.
+func (f *FstabFile) Add(entry MountEntry) {
+	line := fmt.Sprintf("%s\t%s\t%s\t%s\t0\t0", entry.Device, entry.MountPoint, entry.FSType, strings.Join(entry.Options, ","))
+
+	// Keep the trailing newline at the end of the file.
+	if len(f.lines) != 0 && f.lines[len(f.lines)-1] == "" {
+		f.lines = append(f.lines[:len(f.lines)-1], line, "")
+		return
+	}
+
+	f.lines = append(f.lines, line)
+}
+
+// Save writes the file to disk.
+func (f *FstabFile) Save() error {
+	if err := WriteFile(f.path, []byte(strings.Join(f.lines, "\n")), 0644); err != nil {
+		return fmt.Errorf("unable to write to %s: %s", f.path, err.Error())
+	}
+
+	return nil
+}
+
+// index returns the index of the line for the given mount point, or -1 if there is none. If the
+// mount point is listed more than once, the last entry is used, since it is mounted last.
+func (f *FstabFile) index(mountPoint string) int {
+	index := -1
+	for i, line := range f.lines {
+		if entry, ok := parseMountLine(line); ok && entry.MountPoint == mountPoint {
+			index = i
+		}
+	}
+
+	return index
+}
+
+// replaceField replaces the whitespace separated field at the given index, keeping the whitespace
+// around it as it was. If the line ends right before the field, the field is added to it.
+func replaceField(line string, index int, value string) string {
+	field := -1
+	start := -1
+	for i, c := range line {
+		if unicode.IsSpace(c) {
+			if start != -1 {
+				if field == index {
+					return line[:start] + value + line[i:]
+				}
+				start = -1
+			}
+
+			continue
+		}
+
+		if start == -1 {
+			start = i
+			field++
+		}
+	}
+
+	if start != -1 && field == index {
+		return line[:start] + value
+	}
+
+	if field == index-1 {
+		return strings.TrimRightFunc(line, unicode.IsSpace) + "\t" + value
+	}
+
+	return line
+}
@@ -0,0 +1,193 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// INIFile is an INI style config file that can be edited without losing its comments or formatting.
+type INIFile struct {
+	path    string
+	lines   []string
+	changed bool
+}
+
+// LoadINIFile will load the given INI file. If the file does not exist, an empty file is
+// returned, which will be created once it is saved.
+func LoadINIFile(file string) (*INIFile, error) {
+	buffer, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return &INIFile{path: file}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", file, err.Error())
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(buffer), "\n"), "\n")
+	return &INIFile{path: file, lines: lines}, nil
+}
+
+// Path returns the path of the file.
+func (f *INIFile) Path() string {
+	return f.path
+}
+
+// Changed returns true if the file has been modified since it was loaded.
+func (f *INIFile) Changed() bool {
+	return f.changed
+}
+
+// HasSection returns true if the file contains the given section.
+func (f *INIFile) HasSection(section string) bool {
+	start, _ := f.sectionBounds(section)
+	return start != -1
+}
+
+// Sections returns the names of every section in the file, in order.
+func (f *INIFile) Sections() []string {
+	sections := []string{}
+	for _, line := range f.lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			sections = append(sections, line[1:len(line)-1])
+		}
+	}
+
+	return sections
+}
+
+// Get returns the value of the key in the given section. The last return value is false if
+// the key is not set.
+func (f *INIFile) Get(section, key string) (string, bool) {
+	start, end := f.sectionBounds(section)
+	if start == -1 {
+		return "", false
+	}
+
+	for i := start + 1; i < end; i++ {
+		if k, v, ok := parseINILine(f.lines[i]); ok && k == key {
+			return v, true
+		}
+	}
+
+	return "", false
+}
+
+// Set sets the value of the key in the given section, and returns the previous value. If the key
+// is not set but a commented out version of it exists, that line is replaced instead. The section
+// is created if it does not exist.
+func (f *INIFile) Set(section, key, value string) (string, bool) {
+	line := key + "=" + value
+	start, end := f.sectionBounds(section)
+	if start == -1 {
+		if len(f.lines) != 0 && strings.TrimSpace(f.lines[len(f.lines)-1]) != "" {
+			f.lines = append(f.lines, "")
+		}
+
+		f.lines = append(f.lines, "["+section+"]", line)
+		f.changed = true
+		return "", false
+	}
+
+	commented := -1
+	for i := start + 1; i < end; i++ {
+		if k, v, ok := parseINILine(f.lines[i]); ok && k == key {
+			if v != value {
+				f.lines[i] = line
+				f.changed = true
+			}
+
+			return v, true
+		}
+
+		trimmed := strings.TrimLeft(strings.TrimSpace(f.lines[i]), "#;")
+		if k, _, ok := parseINILine(trimmed); ok && k == key && commented == -1 {
+			commented = i
+		}
+	}
+
+	f.changed = true
+	if commented != -1 {
+		f.lines[commented] = line
+		return "", false
+	}
+
+	// Insert the key after the last non-empty line of the section.
+	insert := end
+	for insert > start+1 && strings.TrimSpace(f.lines[insert-1]) == "" {
+		insert--
+	}
+
+	f.lines = append(f.lines[:insert], append([]string{line}, f.lines[insert:]...)...)
+	return "", false
+}
+
+// Delete removes the key from the given section, and returns true if it was set.
+func (f *INIFile) Delete(section, key string) bool {
+	start, end := f.sectionBounds(section)
+	if start == -1 {
+		return false
+	}
+
+	for i := start + 1; i < end; i++ {
+		if k, _, ok := parseINILine(f.lines[i]); ok && k == key {
+			f.lines = append(f.lines[:i], f.lines[i+1:]...)
+			f.changed = true
+			return true
+		}
+	}
+
+	return false
+}
+
+// Save writes the file to disk if it has been changed.
+func (f *INIFile) Save() error {
+	if !f.changed {
+		return nil
+	}
+
+	if err := WriteFile(f.path, []byte(strings.Join(f.lines, "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("unable to write to %s: %s", f.path, err.Error())
+	}
+
+	f.changed = false
+	return nil
+}
+
+// sectionBounds returns the index of the header of the given section, and the index of the line
+// after the last line of the section. The start index is -1 if the section does not exist.
+func (f *INIFile) sectionBounds(section string) (int, int) {
+	start := -1
+	for i, line := range f.lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+			continue
+		}
+
+		if start != -1 {
+			return start, i
+		}
+
+		if line[1:len(line)-1] == section {
+			start = i
+		}
+	}
+
+	return start, len(f.lines)
+}
+
+// parseINILine returns the key and value of a key=value line. The last return value is false
+// if the line is not a key=value line.
+func parseINILine(line string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+		return "", "", false
+	}
+
+	split := strings.SplitN(line, "=", 2)
+	if len(split) != 2 {
+		return "", "", false
+	}
+
+	return strings.TrimSpace(split[0]), strings.TrimSpace(split[1]), true
+}
@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MountEntry is a single filesystem listed in /proc/self/mounts or /etc/fstab.
+type MountEntry struct {
+	Device     string
+	MountPoint string
+	FSType     string
+	Options    []string
+}
+
+// HasOption returns true if the mount entry has the given option.
+func (m MountEntry) HasOption(opt string) bool {
+	for _, o := range m.Options {
+		if o == opt {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ReadMounts will return the filesystems listed in the given mounts file, such as /proc/self/mounts.
+func ReadMounts(file string) ([]MountEntry, error) {
+	buffer, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", file, err.Error())
+	}
+
+	mounts := []MountEntry{}
+	for _, line := range strings.Split(string(buffer), "\n") {
+		if entry, ok := parseMountLine(line); ok {
+			mounts = append(mounts, entry)
+		}
+	}
+
+	return mounts, nil
+}
+
+// parseMountLine parses a line in the fstab format. The last return value is false if the line
+// is empty or a comment.
+func parseMountLine(line string) (MountEntry, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || strings.HasPrefix(fields[0], "#") {
+		return MountEntry{}, false
+	}
+
+	// The options field can be left out of fstab, in which case the defaults are used.
+	options := []string{"defaults"}
+	if len(fields) >= 4 {
+		options = strings.Split(fields[3], ",")
+	}
+
+	return MountEntry{
+		Device:     unescapeMountField(fields[0]),
+		MountPoint: unescapeMountField(fields[1]),
+		FSType:     fields[2],
+		Options:    options,
+	}, true
+}
+
+// unescapeMountField decodes the octal escapes (such as \040 for a space) used in mount fields.
+func unescapeMountField(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// PasswdEntry is a single account listed in /etc/passwd.
+type PasswdEntry struct {
+	Name  string
+	UID   int
+	GID   int
+	Home  string
+	Shell string
+}
+
+// ReadPasswd will return all the accounts listed in the given passwd file.
+func ReadPasswd(file string) ([]PasswdEntry, error) {
+	buffer, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", file, err.Error())
+	}
+
+	entries := []PasswdEntry{}
+	for _, line := range strings.Split(string(buffer), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// name:password:uid:gid:gecos:home:shell
+		split := strings.Split(line, ":")
+		if len(split) < 7 {
+			continue
+		}
+
+		uid, err := strconv.Atoi(split[2])
+		if err != nil {
+			continue
+		}
+
+		gid, err := strconv.Atoi(split[3])
+		if err != nil {
+			continue
+		}
+
+		entries = append(entries, PasswdEntry{
+			Name:  split[0],
+			UID:   uid,
+			GID:   gid,
+			Home:  split[5],
+			Shell: split[6],
+		})
+	}
+
+	return entries, nil
+}
+
+// GroupEntry is a single group listed in /etc/group.
+type GroupEntry struct {
+	Name    string
+	GID     int
+	Members []string
+}
+
+// ReadGroup will return all the groups listed in the given group file.
+func ReadGroup(file string) ([]GroupEntry, error) {
+	buffer, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %s: %s", file, err.Error())
+	}
+
+	entries := []GroupEntry{}
+	for _, line := range strings.Split(string(buffer), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// name:password:gid:members
+		split := strings.Split(line, ":")
+		if len(split) < 4 {
+			continue
+		}
+
+		gid, err := strconv.Atoi(split[2])
+		if err != nil {
+			continue
+		}
+
+		members := []string{}
+		if split[3] != "" {
+			members = strings.Split(split[3], ",")
+		}
+
+		entries = append(entries, GroupEntry{Name: split[0], GID: gid, Members: members})
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package utils
+
+import (
+	"os"
+	"syscall"
+)
+
+// FileOwner returns the uid and gid of the owner of the given file. The last return value
+// is false if the owner could not be determined.
+func FileOwner(info os.FileInfo) (int, int, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return -1, -1, false
+	}
+
+	return int(stat.Uid), int(stat.Gid), true
+}
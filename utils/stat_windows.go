@@ -0,0 +1,9 @@
+package utils
+
+import "os"
+
+// FileOwner returns the uid and gid of the owner of the given file. File ownership is not
+// represented by uids and gids on Windows, so the owner can never be determined.
+func FileOwner(info os.FileInfo) (int, int, bool) {
+	return -1, -1, false
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"strings"
+	"syscall"
+)
+
+// copyXattrs copies the extended attributes of the source file onto the destination file, which holds
+// its SELinux label (security.selinux) and ACLs (system.posix_acl_access). Attributes that can not be
+// copied are skipped.
+func copyXattrs(src, dst string) {
+	size, err := syscall.Listxattr(src, nil)
+	if err != nil || size <= 0 {
+		return
+	}
+
+	buffer := make([]byte, size)
+	if size, err = syscall.Listxattr(src, buffer); err != nil {
+		return
+	}
+
+	// The names are separated by null bytes.
+	for _, name := range strings.Split(string(buffer[:size]), "\x00") {
+		if name == "" {
+			continue
+		}
+
+		n, err := syscall.Getxattr(src, name, nil)
+		if err != nil || n < 0 {
+			continue
+		}
+
+		value := make([]byte, n)
+		if n, err = syscall.Getxattr(src, name, value); err != nil {
+			continue
+		}
+
+		syscall.Setxattr(dst, name, value[:n], 0)
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !linux
+
+package utils
+
+// copyXattrs does nothing on systems other than Linux.
+func copyXattrs(src, dst string) {}